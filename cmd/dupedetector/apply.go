@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/soyunomas/dupedetector/pkg/dupes"
+)
+
+// runApply implementa `dupedetector apply <reporte.json> -trash|-delete|-link`.
+// Ejecuta las acciones de un reporte guardado con -json (posiblemente revisado
+// o editado a mano), pero solo tras confirmar que nada cambió desde el escaneo.
+func runApply(args []string) {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	deletePtr := fs.Bool("delete", false, "⚠️  BORRADO NUCLEAR: Elimina archivos inmediatamente")
+	trashPtr := fs.Bool("trash", false, "♻️  SOFT DELETE: Mueve archivos a una carpeta ./TRASH_BIN")
+	xdgTrashPtr := fs.Bool("xdg-trash", false, "♻️  Como -trash, pero usando la papelera del escritorio (freedesktop.org)")
+	linkPtr := fs.String("link", "", "🔗 Reemplaza duplicados por enlaces al keeper: "+LinkHard+", "+LinkReflink+", "+LinkSymlink)
+	relativePtr := fs.Bool("relative", false, "Con -link symlink: destino relativo al directorio de la víctima (default: absoluto)")
+	journalPtr := fs.String("journal", "", "📒 Ruta del journal de acciones (default: ./dupedetector-journal-FECHA.jsonl)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Uso: dupedetector apply <reporte.json> [-trash|-xdg-trash|-delete|-link MODO]")
+		fs.PrintDefaults()
+	}
+
+	positional := parseInterleaved(fs, args)
+	if len(positional) != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if *xdgTrashPtr {
+		*trashPtr = true
+	}
+	actionCount := 0
+	for _, on := range []bool{*deletePtr, *trashPtr, *linkPtr != ""} {
+		if on {
+			actionCount++
+		}
+	}
+	if actionCount > 1 {
+		fmt.Fprintln(os.Stderr, "❌ Error: Solo puedes elegir UNA acción: -delete, -trash, o -link")
+		os.Exit(1)
+	}
+
+	link := linkConfig{Mode: strings.ToLower(*linkPtr), Relative: *relativePtr}
+	if !validLinkMode(link.Mode) {
+		fmt.Fprintf(os.Stderr, "❌ Modo de enlace desconocido: %s\n", *linkPtr)
+		os.Exit(1)
+	}
+
+	report, err := loadReport(positional[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error leyendo reporte: %v\n", err)
+		os.Exit(1)
+	}
+
+	h, err := dupes.NewHasher(report.Metadata.HashAlgorithm)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("📥 Aplicando reporte: %s (%d grupos, hash %s)\n", positional[0], len(report.Groups), h.Name())
+	ctx := signalContext()
+	fmt.Println("🔍 Re-verificando Keepers y víctimas contra el reporte...")
+	confirmed := confirmReport(ctx, report, h)
+	fmt.Println("------------------------------------------------")
+	if ctx.Err() != nil {
+		fmt.Println("⏹️  Interrumpido durante la re-verificación: no se tocó ningún archivo.")
+		os.Exit(exitInterrupted)
+	}
+
+	act := actionConfig{
+		Delete: *deletePtr,
+		Trash:  *trashPtr,
+		XDG:    *xdgTrashPtr,
+		Link:   link,
+	}
+	if err := act.openJournal(*journalPtr); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error creando journal: %v\n", err)
+		os.Exit(1)
+	}
+
+	interrupted := processResults(ctx, confirmed, act)
+	if act.Journal != nil {
+		act.Journal.Close()
+	}
+	if interrupted {
+		os.Exit(exitInterrupted)
+	}
+}
+
+// loadReport lee un reporte generado con -json.
+func loadReport(path string) (Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Report{}, err
+	}
+	defer f.Close()
+
+	var r Report
+	if err := json.NewDecoder(f).Decode(&r); err != nil {
+		return Report{}, err
+	}
+	return r, nil
+}
+
+// confirmReport re-stat y re-hash de cada Keeper y víctima. Devuelve un reporte
+// que solo contiene lo que sigue coincidiendo con el escaneo original.
+// Si ctx se cancela se detiene y devuelve lo confirmado hasta entonces.
+func confirmReport(ctx context.Context, r Report, h dupes.Hasher) Report {
+	out := Report{Metadata: r.Metadata, Summary: Summary{TotalFilesScanned: r.Summary.TotalFilesScanned}}
+
+	for _, g := range r.Groups {
+		if ctx.Err() != nil {
+			break
+		}
+		if g.Keeper == nil || len(g.Victims) == 0 {
+			continue
+		}
+
+		// Un Keeper dentro de un comprimido no se puede enlazar ni re-verificar en disco
+		if g.Keeper.Archive != "" {
+			fmt.Printf("   ⏭️  Grupo omitido, el Keeper está dentro de un archivo comprimido: %s\n", g.Keeper.Path)
+			continue
+		}
+
+		// Sin Keeper intacto no hay nada que proteger: se salta el grupo entero
+		keeperStats, err := verifyUnchanged(g.Keeper.Path, g.Size, g.Keeper.ModTime, g.Hash, h)
+		if err != nil {
+			fmt.Printf("   ⏭️  Grupo omitido, Keeper %s: %v\n", g.Keeper.Path, err)
+			continue
+		}
+
+		confirmedGroup := g
+		confirmedGroup.Victims = nil
+		for _, v := range g.Victims {
+			if v.Path == g.Keeper.Path {
+				fmt.Printf("   ⏭️  Omitido, la víctima es el propio Keeper: %s\n", v.Path)
+				continue
+			}
+			// Reporte editado a mano o antiguo: lo que cuelga de un -ref nunca es víctima
+			if ref, ok := underAny(v.Path, r.Metadata.References); ok {
+				fmt.Printf("   ⏭️  Omitido, está en la carpeta de referencia %s: %s\n", ref, v.Path)
+				continue
+			}
+			// Reporte editado a mano: dentro de un comprimido nunca se actúa
+			if r.Metadata.Archives && inArchive(v.Path) {
+				fmt.Printf("   ⏭️  Omitido, está dentro de un archivo comprimido: %s\n", v.Path)
+				continue
+			}
+			stats, err := verifyUnchanged(v.Path, v.Size, v.ModTime, g.Hash, h)
+			if err != nil {
+				fmt.Printf("   ⏭️  Omitido %s: %v\n", v.Path, err)
+				continue
+			}
+			if stats.DeviceID == keeperStats.DeviceID && stats.Inode == keeperStats.Inode {
+				fmt.Printf("   ⏭️  Omitido, ya es un hard link del Keeper: %s\n", v.Path)
+				continue
+			}
+			confirmedGroup.Victims = append(confirmedGroup.Victims, v)
+			out.Summary.TotalDuplicates++
+			out.Summary.BytesSaved += v.Size
+		}
+
+		if len(confirmedGroup.Victims) > 0 {
+			out.Groups = append(out.Groups, confirmedGroup)
+		}
+	}
+
+	out.Summary.BytesSavedHuman = dupes.ByteCountDecimal(out.Summary.BytesSaved)
+	return out
+}
+
+// underAny devuelve la raíz (de roots) que contiene path, comparando en absoluto.
+func underAny(path string, roots []string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = filepath.Clean(path)
+	}
+	for _, root := range roots {
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			absRoot = filepath.Clean(root)
+		}
+		if rel, err := filepath.Rel(absRoot, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return root, true
+		}
+	}
+	return "", false
+}
+
+// inArchive indica si p es la ruta de un miembro de comprimido. Una carpeta real
+// también puede llamarse "copia.zip!": solo cuenta si p no existe en disco y el
+// comprimido sí, como archivo regular.
+func inArchive(p string) bool {
+	archivePath, _, ok := dupes.SplitArchivePath(p)
+	if !ok {
+		return false
+	}
+	if _, err := os.Lstat(p); err == nil {
+		return false
+	}
+	info, err := os.Stat(archivePath)
+	return err == nil && info.Mode().IsRegular()
+}
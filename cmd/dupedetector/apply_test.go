@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/soyunomas/dupedetector/pkg/dupes"
+)
+
+func TestConfirmReport(t *testing.T) {
+	h, err := dupes.NewHasher("xxhash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		refDir bool // la víctima cuelga de la carpeta -ref del reporte
+		change func(t *testing.T, keeper, victim string) string
+		keep   bool
+	}{
+		{"intacta", false, func(t *testing.T, k, v string) string { return v }, true},
+		{"contenido cambiado", false, func(t *testing.T, k, v string) string {
+			writeFile(t, filepath.Dir(v), filepath.Base(v), "XXXXXXXX")
+			return v
+		}, false},
+		{"tamaño cambiado", false, func(t *testing.T, k, v string) string {
+			writeFile(t, filepath.Dir(v), filepath.Base(v), "DATOS")
+			return v
+		}, false},
+		{"desaparecida", false, func(t *testing.T, k, v string) string {
+			os.Remove(v)
+			return v
+		}, false},
+		{"en la referencia", true, func(t *testing.T, k, v string) string { return v }, false},
+		{"es el keeper", false, func(t *testing.T, k, v string) string { return k }, false},
+		{"hard link del keeper", false, func(t *testing.T, k, v string) string {
+			os.Remove(v)
+			if err := os.Link(k, v); err != nil {
+				t.Fatal(err)
+			}
+			return v
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			keeper := writeFile(t, dir, "keeper.txt", "DATOS123")
+			victimDir := "copias"
+			if tt.refDir {
+				victimDir = "ref"
+			}
+			victim := writeFile(t, dir, filepath.Join(victimDir, "victim.txt"), "DATOS123")
+			digest, _, err := h.HashFile(context.Background(), dupes.OS, keeper, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			victim = tt.change(t, keeper, victim)
+			report := Report{
+				Metadata: Metadata{References: []string{filepath.Join(dir, "ref")}},
+				Groups: []GroupResult{{
+					Hash:    digest,
+					Size:    8,
+					Keeper:  &dupes.File{Path: keeper, Size: 8},
+					Victims: []Victim{{Path: victim, Size: 8}},
+				}},
+			}
+
+			got := confirmReport(context.Background(), report, h)
+			if kept := len(got.Groups) == 1; kept != tt.keep {
+				t.Fatalf("víctima confirmada = %v, quería %v", kept, tt.keep)
+			}
+			if tt.keep && got.Summary.TotalDuplicates != 1 {
+				t.Errorf("TotalDuplicates = %d", got.Summary.TotalDuplicates)
+			}
+		})
+	}
+}
+
+// Si el Keeper cambió desde el escaneo, el grupo entero se descarta.
+func TestConfirmReportKeeperChanged(t *testing.T) {
+	h, err := dupes.NewHasher("xxhash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	keeper := writeFile(t, dir, "keeper.txt", "DATOS123")
+	victim := writeFile(t, dir, "victim.txt", "DATOS123")
+	digest, _, err := h.HashFile(context.Background(), dupes.OS, keeper, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "keeper.txt", "OTROS123")
+
+	report := Report{Groups: []GroupResult{{
+		Hash:    digest,
+		Size:    8,
+		Keeper:  &dupes.File{Path: keeper, Size: 8},
+		Victims: []Victim{{Path: victim, Size: 8}},
+	}}}
+	if got := confirmReport(context.Background(), report, h); len(got.Groups) != 0 {
+		t.Errorf("grupo con Keeper modificado confirmado: %+v", got.Groups)
+	}
+}
+
+func TestInArchive(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "backup.zip", "PK")
+	real := writeFile(t, dir, "copia.zip!/a.txt", "a")
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{filepath.Join(dir, "backup.zip!/a.txt"), true},
+		{real, false}, // Carpeta real llamada "copia.zip!"
+		{filepath.Join(dir, "falta.zip!/a.txt"), false},
+		{filepath.Join(dir, "a.txt"), false},
+	}
+	for _, tt := range tests {
+		if got := inArchive(tt.path); got != tt.want {
+			t.Errorf("inArchive(%s) = %v, quería %v", tt.path, got, tt.want)
+		}
+	}
+}
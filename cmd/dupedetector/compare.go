@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/soyunomas/dupedetector/pkg/dupes"
+)
+
+// exitUnique: compare terminó y hay archivos de B sin copia en A (o sin comparar).
+const exitUnique = 4
+
+// --- ESTRUCTURAS PARA EL REPORTE DE compare ---
+
+type CompareReport struct {
+	Summary  CompareSummary    `json:"summary"`
+	Found    []CompareMatch    `json:"found"`   // Archivos de B con copia en A
+	Unique   []CompareFile     `json:"unique"`  // Archivos de B sin copia en A
+	Skipped  []string          `json:"skipped"` // Entradas de B que no se compararon
+	Errors   []dupes.PathError `json:"errors"`  // Vacío = escaneo completo
+	Metadata CompareMetadata   `json:"metadata"`
+}
+
+type CompareMetadata struct {
+	Source        string    `json:"source"` // A
+	Target        string    `json:"target"` // B
+	HashAlgorithm string    `json:"hash_algorithm"`
+	ByteVerify    bool      `json:"byte_verify"`
+	Archives      bool      `json:"archives,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+	Duration      string    `json:"duration_human"`
+}
+
+type CompareSummary struct {
+	TotalFilesScanned int64 `json:"total_files_scanned"` // En B
+	SourceFiles       int64 `json:"source_files_scanned"`
+	TotalFound        int64 `json:"total_found"`
+	FoundBytes        int64 `json:"found_bytes"`
+	TotalUnique       int64 `json:"total_unique"`
+	UniqueBytes       int64 `json:"unique_bytes"`
+	TotalSkipped      int64 `json:"total_skipped"`
+	TotalErrors       int64 `json:"total_errors"`
+	Incomplete        bool  `json:"incomplete"` // Interrumpido (Ctrl-C): reporte parcial
+	SafeToWipe        bool  `json:"safe_to_wipe"`
+}
+
+type CompareMatch struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Copy     string `json:"copy"` // Dónde está en A
+	Verified bool   `json:"byte_verified"`
+}
+
+type CompareFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// runCompare implementa `dupedetector compare A B`: qué archivos de B ya tienen
+// copia en A y cuáles solo están en B. Nunca modifica nada.
+func runCompare(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	minSize, maxSize := sizeFlag(0), sizeFlag(0)
+	fs.Var(&minSize, "min-size", "Tamaño mínimo (bytes o 100KB, 1.5GB, 4MiB...)")
+	fs.Var(&maxSize, "max-size", "Tamaño máximo (0 = sin límite)")
+	var exts, excludeExts, excludes, includes, excludeRegex, includeRegex stringList
+	fs.Var(&exts, "ext", "Solo estas extensiones (repetible o separadas por comas: mp4,mkv)")
+	fs.Var(&excludeExts, "exclude-ext", "Ignorar estas extensiones (repetible o separadas por comas)")
+	fs.Var(&excludes, "exclude", "🚫 Glob a ignorar (repetible): '*.tmp', '**/cache/*', 'build/'")
+	fs.Var(&includes, "include", "✅ Glob de archivos a considerar (repetible): '*.jpg', 'fotos/**'")
+	fs.Var(&excludeRegex, "exclude-regex", "🚫 Regex sobre la ruta relativa a ignorar (repetible)")
+	fs.Var(&includeRegex, "include-regex", "✅ Regex sobre la ruta relativa a considerar (repetible)")
+	followPtr := fs.Bool("follow-symlinks", false, "🔗 Seguir enlaces simbólicos (con detección de bucles). Por defecto se ignoran")
+	oneFSPtr := fs.Bool("one-file-system", false, "💽 No cruzar puntos de montaje (como find -xdev)")
+	archivesPtr := fs.Bool("archives", false, "🗜️  Buscar copias también dentro de .zip, .tar, .tar.gz y .tar.bz2 de A")
+	keepPtr := fs.String("keep", "shortest", "Qué copia de A mostrar: shortest, longest, oldest, newest")
+	gitignorePtr := fs.Bool("gitignore", false, "Respetar también los .gitignore (además de "+dupes.DefaultIgnoreFile+")")
+	hashPtr := fs.String("hash", dupes.AlgoXXHash, "Algoritmo de hash: "+strings.Join(dupes.Algorithms(), ", "))
+	noCachePtr := fs.Bool("no-cache", false, "No usar la caché persistente de hashes ($XDG_CACHE_HOME/dupedetector)")
+	verifyPtr := fs.Bool("verify", false, "🔬 Comparación byte a byte contra la copia en A")
+	foundPtr := fs.Bool("show-found", false, "Listar también los archivos de B que ya están en A")
+	jsonPtr := fs.Bool("json", false, "Salida en formato JSON a stdout")
+	quietPtr := fs.Bool("quiet", false, "🤫 Sin mensajes de progreso (stderr)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Uso: dupedetector compare [flags] <A: donde deberían estar las copias> <B: carpeta a revisar>")
+		fs.PrintDefaults()
+	}
+
+	positional := parseInterleaved(fs, args)
+	if len(positional) != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	source, target := positional[0], positional[1]
+
+	strategy, err := dupes.ParseKeepStrategy(*keepPtr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(2)
+	}
+
+	// Todo lo que se salte en B (TRASH_BIN, .dupeignore, filtros, symlinks...) se lista
+	// en Skipped e impide safe_to_wipe: lo que no se mira no puede darse por copiado
+	for _, p := range append(append([]string{}, excludes...), includes...) {
+		if err := dupes.ValidatePattern(p); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(2)
+		}
+	}
+	exRegex, err := compileRegexes(excludeRegex)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ -exclude-regex: %v\n", err)
+		os.Exit(2)
+	}
+	inRegex, err := compileRegexes(includeRegex)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ -include-regex: %v\n", err)
+		os.Exit(2)
+	}
+	ignoreFiles := []string{dupes.DefaultIgnoreFile}
+	if *gitignorePtr {
+		ignoreFiles = append(ignoreFiles, ".gitignore")
+	}
+
+	opts := dupes.Options{
+		MinSize:           int64(minSize),
+		MaxSize:           int64(maxSize),
+		Extensions:        splitList(exts),
+		ExcludeExtensions: splitList(excludeExts),
+		Excludes:          append([]string{"TRASH_BIN"}, excludes...),
+		Includes:          includes,
+		ExcludeRegex:      exRegex,
+		IncludeRegex:      inRegex,
+		IgnoreFiles:       ignoreFiles,
+		FollowSymlinks:    *followPtr,
+		OneFileSystem:     *oneFSPtr,
+		Archives:          *archivesPtr,
+		HashAlgorithm:     *hashPtr,
+		Verify:            *verifyPtr,
+		Keep:              strategy,
+		UseCache:          !*noCachePtr,
+	}
+	if !*quietPtr {
+		opts.Progress = newConsoleProgress(os.Stderr, opts.UseCache).Handle
+	}
+
+	if !*jsonPtr {
+		fmt.Printf("🔀 Dupedetector compare - ¿Qué hay en %s que no esté en %s?\n", target, source)
+		fmt.Println("------------------------------------------------")
+	}
+
+	ctx := signalContext()
+	res, err := dupes.Compare(ctx, []string{source}, []string{target}, opts)
+	if err != nil && (res == nil || !res.Interrupted) {
+		die(err, *jsonPtr)
+	}
+	if res.CacheErr != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  %v\n", res.CacheErr)
+	}
+
+	report := generateCompareReport(res, source, target, *verifyPtr, *archivesPtr)
+	if *jsonPtr {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	} else {
+		printComparison(report, *foundPtr)
+		printErrors(report.Errors)
+	}
+	os.Exit(compareExitCode(report))
+}
+
+// generateCompareReport convierte el resultado de dupes.Compare al formato de reporte.
+func generateCompareReport(res *dupes.Comparison, source, target string, verify, archives bool) CompareReport {
+	rep := CompareReport{
+		Metadata: CompareMetadata{
+			Source:        source,
+			Target:        target,
+			HashAlgorithm: res.HashAlgorithm,
+			ByteVerify:    verify,
+			Archives:      archives,
+			Timestamp:     time.Now(),
+			Duration:      res.Duration.String(),
+		},
+		Summary: CompareSummary{
+			TotalFilesScanned: res.FilesScanned,
+			SourceFiles:       res.SourceFiles,
+			TotalFound:        int64(len(res.Matches)),
+			FoundBytes:        res.MatchedBytes,
+			TotalUnique:       int64(len(res.Unique)),
+			UniqueBytes:       res.UniqueBytes,
+			TotalSkipped:      int64(len(res.Skipped)),
+			TotalErrors:       int64(len(res.Errors)),
+			Incomplete:        res.Interrupted,
+			SafeToWipe:        res.Complete(),
+		},
+		Found:   []CompareMatch{},
+		Unique:  []CompareFile{},
+		Skipped: append([]string{}, res.Skipped...),
+		Errors:  append([]dupes.PathError{}, res.Errors...),
+	}
+	for _, m := range res.Matches {
+		rep.Found = append(rep.Found, CompareMatch{Path: m.File.Path, Size: m.File.Size, Copy: m.Copy.Path, Verified: m.Verified})
+	}
+	for _, f := range res.Unique {
+		rep.Unique = append(rep.Unique, CompareFile{Path: f.Path, Size: f.Size, ModTime: f.ModTime})
+	}
+	return rep
+}
+
+// printComparison muestra los archivos únicos de B (lo que importa antes de vaciarla)
+// y, con -show-found, dónde está en A cada uno de los demás.
+func printComparison(r CompareReport, showFound bool) {
+	s := r.Summary
+	if r.Summary.Incomplete {
+		fmt.Println("\n⏹️  Comparación INTERRUMPIDA: reporte parcial.")
+	}
+
+	if showFound && len(r.Found) > 0 {
+		fmt.Printf("🟢 YA ESTÁN EN %s:\n", r.Metadata.Source)
+		for _, m := range r.Found {
+			tag := ""
+			if m.Verified {
+				tag = " | 🔬 Verificado"
+			}
+			fmt.Printf("   ✅ %s -> %s%s\n", m.Path, m.Copy, tag)
+		}
+		fmt.Println("")
+	}
+
+	if len(r.Unique) > 0 {
+		fmt.Printf("🔴 SOLO EN %s:\n", r.Metadata.Target)
+		for _, f := range r.Unique {
+			fmt.Printf("   📄 %s (%s)\n", f.Path, dupes.ByteCountDecimal(f.Size))
+		}
+		fmt.Println("")
+	}
+
+	if len(r.Skipped) > 0 {
+		fmt.Printf("⏭️  NO COMPARADOS EN %s (filtros, exclusiones, symlinks...):\n", r.Metadata.Target)
+		for _, p := range r.Skipped {
+			fmt.Printf("   ⏭️  %s\n", p)
+		}
+		fmt.Println("")
+	}
+
+	fmt.Println("------------------------------------------------")
+	fmt.Printf("🏁 Archivos en %s: %d\n", r.Metadata.Target, s.TotalFilesScanned)
+	fmt.Printf("   ✅ Con copia en %s: %d (%s)\n", r.Metadata.Source, s.TotalFound, dupes.ByteCountDecimal(s.FoundBytes))
+	fmt.Printf("   📄 Solo en %s: %d (%s)\n", r.Metadata.Target, s.TotalUnique, dupes.ByteCountDecimal(s.UniqueBytes))
+	if s.TotalSkipped > 0 {
+		fmt.Printf("   ⏭️  Sin comparar: %d\n", s.TotalSkipped)
+	}
+	switch {
+	case s.SafeToWipe:
+		fmt.Printf("🧹 Todo lo escaneado en %s ya está en %s.\n", r.Metadata.Target, r.Metadata.Source)
+	case s.TotalUnique > 0:
+		fmt.Printf("⚠️  No vacíes %s: hay %d archivos sin copia en %s.\n", r.Metadata.Target, s.TotalUnique, r.Metadata.Source)
+	case s.TotalSkipped > 0:
+		fmt.Printf("⚠️  No vacíes %s: hay %d entradas que no se compararon.\n", r.Metadata.Target, s.TotalSkipped)
+	default:
+		fmt.Println("⚠️  Comparación incompleta: no se puede asegurar que todo esté copiado.")
+	}
+}
+
+// compareExitCode: como exitCode, más exitUnique si hay archivos solo en B o sin comparar.
+func compareExitCode(r CompareReport) int {
+	switch {
+	case r.Summary.Incomplete:
+		return exitInterrupted
+	case len(r.Errors) > 0:
+		return exitIncomplete
+	case r.Summary.TotalUnique > 0 || r.Summary.TotalSkipped > 0:
+		return exitUnique
+	}
+	return 0
+}
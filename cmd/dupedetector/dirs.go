@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/soyunomas/dupedetector/pkg/dupes"
+)
+
+// processDirs es processResults para -dirs: muestra las carpetas redundantes y,
+// con -trash/-xdg-trash, las mueve enteras a la papelera.
+// Devuelve true si se interrumpió antes de terminar.
+func processDirs(ctx context.Context, r Report, act actionConfig) bool {
+	if len(r.Dirs) == 0 {
+		fmt.Println("✅ No se encontraron carpetas redundantes.")
+		printFileGroupsNote(r)
+		return false
+	}
+
+	trash, trashAction := prepareTrash(act, moveDirToTrash)
+
+	fmt.Println("🔴 CARPETAS REDUNDANTES:")
+	actionCount := 0
+	skippedCount := 0
+	var bytesFreed int64
+	interrupted := false
+
+groups:
+	for _, g := range r.Dirs {
+		kind := "Idénticas"
+		if g.Kind == dupes.DirSubset {
+			kind = "Contenidas en"
+		}
+		tags := ""
+		if g.Keeper.Reference {
+			tags += " | 🛡️  Ref"
+		}
+		fmt.Printf("   📁 %s | 👑 KEEPER: %s (%d archivos, %s)%s\n", kind, g.Keeper.Path, g.Keeper.Files, dupes.ByteCountDecimal(g.Keeper.Size), tags)
+
+		// El Keeper puede tener más cosas que las escaneadas (filtros); lo escaneado debe seguir igual
+		var keeperErr error
+		if act.Active() {
+			keeperErr = verifyTree(g.Keeper, act.Rehash, false)
+		}
+
+		for _, d := range g.Redundant {
+			size := fmt.Sprintf("(%d archivos, %s)", d.Files, dupes.ByteCountDecimal(d.Size))
+			if !act.Active() {
+				// DRY RUN
+				fmt.Printf("      🗑️  [Candidata]: %s %s\n", d.Path, size)
+				continue
+			}
+			if ctx.Err() != nil {
+				interrupted = true
+				break groups
+			}
+
+			err := keeperErr
+			if err == nil {
+				if _, serr := os.Lstat(g.Keeper.Path); serr != nil {
+					err = errors.New("ya no existe")
+				}
+			}
+			if err != nil {
+				fmt.Printf("      ⚠️  Omitida (Keeper %s: %v): %s\n", g.Keeper.Path, err, d.Path)
+				skippedCount++
+				continue
+			}
+			if err := verifyTree(d, act.Rehash, true); err != nil {
+				fmt.Printf("      ⚠️  Omitida (%v): %s\n", err, d.Path)
+				skippedCount++
+				continue
+			}
+
+			dest, err := trash(d.Path)
+			if err != nil {
+				fmt.Printf("      ❌ Error moviendo %s: %v\n", d.Path, err)
+				continue
+			}
+			fmt.Printf("      ♻️  Movida a basura: %s %s\n", d.Path, size)
+			actionCount++
+			bytesFreed += d.Size
+			recordDir(act.Journal, trashAction, g.Keeper, d, dest)
+		}
+		fmt.Println("")
+	}
+
+	fmt.Println("------------------------------------------------")
+	if interrupted {
+		fmt.Printf("⏹️  Operación INTERRUMPIDA. Carpetas procesadas: %d (el resto no se tocó)\n", actionCount)
+	} else if act.Active() {
+		fmt.Printf("🏁 Operación completada. Carpetas procesadas: %d\n", actionCount)
+	}
+	if act.Active() {
+		if skippedCount > 0 {
+			fmt.Printf("⚠️  Omitidas por cambios desde el escaneo: %d\n", skippedCount)
+		}
+		if act.Journal != nil {
+			fmt.Printf("📒 Journal: %s (deshacer con: dupedetector restore %s)\n", act.Journal.Path(), act.Journal.Path())
+		}
+		fmt.Printf("💾 Espacio liberado: %s\n", dupes.ByteCountDecimal(bytesFreed))
+	} else {
+		fmt.Printf("🏁 Escaneo terminado. Carpetas redundantes: %d\n", r.Summary.RedundantDirs)
+		fmt.Printf("💾 Espacio recuperable: %s\n", dupes.ByteCountDecimal(r.Summary.DirBytesSaved))
+		printFileGroupsNote(r)
+		fmt.Println("💡 Opciones disponibles:")
+		fmt.Println("   -trash      -> Mover las carpetas redundantes a ./TRASH_BIN")
+		fmt.Println("   -xdg-trash  -> Mover las carpetas redundantes a la papelera del escritorio")
+	}
+	return interrupted
+}
+
+// printFileGroupsNote recuerda que también hay duplicados sueltos (en el JSON o sin -dirs).
+func printFileGroupsNote(r Report) {
+	if len(r.Groups) > 0 {
+		fmt.Printf("📄 Grupos de archivos duplicados: %d (detalle con -json, o sin -dirs)\n", len(r.Groups))
+	}
+}
+
+// verifyTree comprueba, justo antes de actuar, que la carpeta sigue como se
+// escaneó: cada archivo sin cambios (ver verifyUnchanged) y, si exact, nada más
+// dentro: ni un archivo nuevo, ni un symlink, ni uno que se filtró al escanear.
+func verifyTree(d *dupes.Dir, h dupes.Hasher, exact bool) error {
+	info, err := os.Lstat(d.Path)
+	if err != nil {
+		return errors.New("ya no existe")
+	}
+	if !info.IsDir() {
+		return errors.New("ya no es una carpeta")
+	}
+
+	known := make(map[string]bool, len(d.Contents))
+	for _, f := range d.Contents {
+		fh := h
+		if f.Hash == "" {
+			fh = nil // Sin copias en el escaneo: nunca se hasheó
+		}
+		if _, err := verifyUnchanged(f.Path, f.Size, f.ModTime, f.Hash, fh); err != nil {
+			return fmt.Errorf("%s %v", f.Path, err)
+		}
+		known[filepath.Clean(f.Path)] = true
+	}
+	if !exact {
+		return nil
+	}
+
+	return filepath.WalkDir(d.Path, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() || known[path] {
+			return nil
+		}
+		return fmt.Errorf("%s no estaba en el escaneo", path)
+	})
+}
+
+// moveDirToTrash mueve una carpeta entera a trashDir: nombre_TIMESTAMP.
+// Solo por Rename: una carpeta en otro dispositivo no se copia (usa -xdg-trash).
+func moveDirToTrash(srcPath, trashDir string) (string, error) {
+	destPath := filepath.Join(trashDir, fmt.Sprintf("%s_%d", filepath.Base(srcPath), time.Now().UnixNano()))
+	if err := os.Rename(srcPath, destPath); err != nil {
+		if isCrossDeviceError(err) {
+			return "", errors.New("está en otro dispositivo que ./TRASH_BIN (usa -xdg-trash)")
+		}
+		return "", err
+	}
+	return destPath, nil
+}
+
+// recordDir registra en el journal una carpeta movida (restore la devuelve entera).
+func recordDir(journal *Journal, action string, keeper, d *dupes.Dir, location string) {
+	if journal == nil {
+		return
+	}
+	err := journal.Record(JournalEntry{
+		Action:   action,
+		Original: d.Path,
+		Location: location,
+		Size:     d.Size,
+		Hash:     d.Digest,
+		Keeper:   keeper.Path,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "      ⚠️  Error escribiendo journal: %v\n", err)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/soyunomas/dupedetector/pkg/dupes"
+)
+
+// Acciones registradas en el journal
+const (
+	ActionDelete   = "delete"
+	ActionTrash    = "trash"
+	ActionXDGTrash = "trash-xdg" // Papelera freedesktop.org (con .trashinfo)
+)
+
+// JournalEntry describe una acción ejecutada sobre una víctima.
+// Una línea JSON por entrada (JSON Lines): si el proceso muere a mitad,
+// todo lo escrito hasta ese momento sigue siendo legible.
+type JournalEntry struct {
+	Action    string       `json:"action"`
+	Original  string       `json:"original_path"`
+	Location  string       `json:"new_location,omitempty"` // Vacío si no hay copia (delete, enlaces)
+	Size      int64        `json:"size"`
+	Hash      dupes.Digest `json:"hash"`
+	Keeper    string       `json:"keeper"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+// Journal escribe entradas de forma incremental y sincronizada a disco.
+type Journal struct {
+	f    *os.File
+	path string
+}
+
+// defaultJournalName genera un nombre único en el directorio actual.
+func defaultJournalName() string {
+	return fmt.Sprintf("dupedetector-journal-%s.jsonl", time.Now().Format("20060102-150405"))
+}
+
+// OpenJournal crea el archivo de journal (falla si ya existe para no mezclar ejecuciones).
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{f: f, path: path}, nil
+}
+
+// Path devuelve la ruta del journal.
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// Record añade una entrada. Las rutas se guardan absolutas para poder
+// restaurar desde cualquier directorio de trabajo.
+func (j *Journal) Record(e JournalEntry) error {
+	if abs, err := filepath.Abs(e.Original); err == nil {
+		e.Original = abs
+	}
+	if e.Location != "" {
+		if abs, err := filepath.Abs(e.Location); err == nil {
+			e.Location = abs
+		}
+	}
+	if abs, err := filepath.Abs(e.Keeper); err == nil {
+		e.Keeper = abs
+	}
+	e.Timestamp = time.Now()
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := j.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	// Cada acción queda en disco antes de pasar a la siguiente
+	return j.f.Sync()
+}
+
+// Close cierra el journal.
+func (j *Journal) Close() error {
+	return j.f.Close()
+}
+
+// ReadJournal carga todas las entradas de un journal.
+func ReadJournal(path string) ([]JournalEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []JournalEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e JournalEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("línea %d: %w", lineNo, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, sc.Err()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/soyunomas/dupedetector/pkg/dupes"
+)
+
+// Modos de enlace disponibles para -link
+const (
+	LinkHard    = "hardlink"
+	LinkReflink = "reflink" // Copy-on-write (btrfs, XFS...)
+	LinkSymlink = "symlink"
+)
+
+// linkConfig agrupa las opciones de -link.
+type linkConfig struct {
+	Mode     string
+	Relative bool // symlink: destino relativo al directorio de la víctima
+}
+
+var (
+	// errCrossDevice: keeper y víctima están en sistemas de archivos distintos.
+	errCrossDevice = errors.New("keeper en otro dispositivo")
+	// errReflinkUnsupported: el sistema de archivos no soporta compartir extents.
+	errReflinkUnsupported = errors.New("reflink no soportado por el sistema de archivos")
+	// errContentDiffers: el kernel comparó ambos archivos y no son idénticos.
+	errContentDiffers = errors.New("el contenido difiere del keeper")
+)
+
+// validLinkMode indica si el modo recibido en -link está soportado.
+func validLinkMode(mode string) bool {
+	switch mode {
+	case "", LinkHard, LinkReflink, LinkSymlink:
+		return true
+	}
+	return false
+}
+
+// replaceWithLink sustituye la víctima por un enlace al keeper según el modo.
+// cloned indica un reflink hecho con FICLONE, sin la re-verificación del kernel.
+func replaceWithLink(cfg linkConfig, keeper *dupes.File, victim Victim) (cloned bool, err error) {
+	switch cfg.Mode {
+	case LinkHard:
+		if keeper.DeviceID != victim.DeviceID {
+			return false, errCrossDevice
+		}
+		return false, replaceWithHardLink(keeper.Path, victim.Path)
+	case LinkReflink:
+		// Sin chequeo de DeviceID: los subvolúmenes btrfs tienen st_dev distinto
+		// y aun así comparten extents. Si no se puede, el kernel devuelve EXDEV.
+		return replaceWithReflink(keeper.Path, victim.Path)
+	case LinkSymlink:
+		target, err := symlinkTarget(keeper.Path, victim.Path, cfg.Relative)
+		if err != nil {
+			return false, err
+		}
+		return false, replaceWithSymlink(target, victim.Path)
+	}
+	return false, fmt.Errorf("modo de enlace desconocido: %s", cfg.Mode)
+}
+
+// replaceWithHardLink crea el hard link con un nombre temporal en el mismo
+// directorio y luego lo renombra encima de la víctima. Rename es atómico:
+// en ningún momento la ruta de la víctima deja de existir.
+func replaceWithHardLink(keeperPath, victimPath string) error {
+	tmp := tempSibling(victimPath)
+	if err := os.Link(keeperPath, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, victimPath); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// replaceWithSymlink crea el symlink con un nombre temporal y lo renombra encima
+// de la víctima (mismo esquema atómico que los hard links).
+func replaceWithSymlink(target, victimPath string) error {
+	tmp := tempSibling(victimPath)
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, victimPath); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// symlinkTarget calcula el destino del symlink: la ruta absoluta del keeper,
+// o la ruta relativa desde el directorio de la víctima.
+func symlinkTarget(keeperPath, victimPath string, relative bool) (string, error) {
+	absKeeper, err := filepath.Abs(keeperPath)
+	if err != nil {
+		return "", err
+	}
+	if !relative {
+		return absKeeper, nil
+	}
+
+	absVictim, err := filepath.Abs(victimPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(filepath.Dir(absVictim), absKeeper)
+}
+
+// tempSibling genera un nombre oculto y único junto a path (mismo directorio = mismo FS).
+func tempSibling(path string) string {
+	dir, name := filepath.Split(path)
+	return filepath.Join(dir, fmt.Sprintf(".%s.dupedetector-%d.tmp", name, time.Now().UnixNano()))
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/soyunomas/dupedetector/pkg/dupes"
+)
+
+// writeFile crea dir/name con data y devuelve su ruta.
+func writeFile(t *testing.T, dir, name, data string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// readFile devuelve el contenido de p o falla el test.
+func readFile(t *testing.T, p string) string {
+	t.Helper()
+	data, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// onlyFiles falla si en dir hay algo más que names (p. ej. temporales olvidados).
+func onlyFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{}
+	for _, n := range names {
+		want[n] = true
+	}
+	for _, e := range entries {
+		if !want[e.Name()] {
+			t.Errorf("sobra %s en %s", e.Name(), dir)
+		}
+	}
+}
+
+func TestReplaceWithLink(t *testing.T) {
+	tests := []struct {
+		name      string
+		link      linkConfig
+		victimDev uint64
+		wantErr   error  // Si no es nil, el error esperado
+		fail      bool   // Debe fallar (wantErr o cualquier otro error)
+		target    string // symlink: destino esperado ("" = absoluto al keeper)
+	}{
+		{name: "hardlink", link: linkConfig{Mode: LinkHard}, victimDev: 1},
+		{name: "hardlink entre dispositivos", link: linkConfig{Mode: LinkHard}, victimDev: 2, wantErr: errCrossDevice, fail: true},
+		{name: "symlink absoluto", link: linkConfig{Mode: LinkSymlink}, victimDev: 2},
+		{name: "symlink relativo", link: linkConfig{Mode: LinkSymlink, Relative: true}, victimDev: 1, target: "../keeper.txt"},
+		{name: "modo desconocido", link: linkConfig{Mode: "copia"}, victimDev: 1, fail: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			keeperPath := writeFile(t, dir, "keeper.txt", "mismo contenido")
+			victimPath := writeFile(t, dir, "sub/victim.txt", "mismo contenido")
+			keeper := &dupes.File{Path: keeperPath, DeviceID: 1}
+
+			_, err := replaceWithLink(tt.link, keeper, Victim{Path: victimPath, DeviceID: tt.victimDev})
+			ki, _ := os.Stat(keeperPath)
+			vi, _ := os.Lstat(victimPath)
+			if tt.fail {
+				if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+					t.Fatalf("err = %v, quería %v", err, tt.wantErr)
+				}
+				if !vi.Mode().IsRegular() || os.SameFile(ki, vi) {
+					t.Error("la víctima cambió pese al error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			switch tt.link.Mode {
+			case LinkHard:
+				if !os.SameFile(ki, vi) {
+					t.Error("la víctima no es un hard link del keeper")
+				}
+			case LinkSymlink:
+				target, err := os.Readlink(victimPath)
+				if err != nil {
+					t.Fatalf("la víctima no es un symlink: %v", err)
+				}
+				want := tt.target
+				if want == "" {
+					want = keeperPath
+				}
+				if target != want {
+					t.Errorf("destino = %s, quería %s", target, want)
+				}
+			}
+			if got := readFile(t, victimPath); got != "mismo contenido" {
+				t.Errorf("contenido = %q", got)
+			}
+			onlyFiles(t, filepath.Dir(victimPath), "victim.txt")
+		})
+	}
+}
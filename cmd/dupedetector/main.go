@@ -2,181 +2,572 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
-	"github.com/soyunomas/dupedetector/internal/engine"
-	"github.com/soyunomas/dupedetector/internal/entities"
-	"github.com/soyunomas/dupedetector/internal/utils"
+	"github.com/soyunomas/dupedetector/pkg/dupes"
 )
 
+// exitIncomplete: el escaneo terminó, pero hubo archivos que no se pudieron leer.
+// (1 = error fatal, 2 = flags inválidos)
+const exitIncomplete = 3
+
+// exitInterrupted: cancelado con Ctrl-C/SIGTERM (convención 128 + SIGINT).
+const exitInterrupted = 130
+
 // --- ESTRUCTURAS PARA EL REPORTE FINAL ---
 
 type Report struct {
-	Summary  Summary       `json:"summary"`
-	Groups   []GroupResult `json:"groups"`
-	Metadata Metadata      `json:"metadata"`
+	Summary  Summary           `json:"summary"`
+	Groups   []GroupResult     `json:"groups"`
+	Dirs     []*dupes.DirGroup `json:"directories,omitempty"` // Solo con -dirs
+	Errors   []dupes.PathError `json:"errors"`                // Vacío = escaneo completo
+	Metadata Metadata          `json:"metadata"`
 }
 
 type Metadata struct {
-	ScannedPath string    `json:"scanned_path"`
-	Strategy    string    `json:"strategy"`
-	Timestamp   time.Time `json:"timestamp"`
-	Duration    string    `json:"duration_human"`
+	ScannedPaths  []string  `json:"scanned_paths"`
+	References    []string  `json:"reference_paths,omitempty"`
+	Strategy      string    `json:"strategy"`
+	HashAlgorithm string    `json:"hash_algorithm"`
+	ByteVerify    bool      `json:"byte_verify"`
+	Archives      bool      `json:"archives,omitempty"` // Se buscó dentro de .zip/.tar
+	Dirs          bool      `json:"dirs,omitempty"`     // Se buscaron carpetas redundantes
+	Timestamp     time.Time `json:"timestamp"`
+	Duration      string    `json:"duration_human"`
 }
 
 type Summary struct {
 	TotalFilesScanned int64  `json:"total_files_scanned"`
 	TotalDuplicates   int64  `json:"total_duplicates"`
 	TotalHardLinks    int64  `json:"total_hard_links"`
+	TotalReferences   int64  `json:"total_reference_copies"`
+	TotalArchived     int64  `json:"total_archived_copies"`
+	VerifiedGroups    int64  `json:"verified_groups"`
 	BytesSaved        int64  `json:"bytes_saved"`
 	BytesSavedHuman   string `json:"bytes_saved_human"`
+	RedundantDirs     int64  `json:"total_redundant_dirs,omitempty"`
+	DirBytesSaved     int64  `json:"dir_bytes_saved,omitempty"`
+	TotalErrors       int64  `json:"total_errors"`
+	Incomplete        bool   `json:"incomplete"` // Interrumpido (Ctrl-C): reporte parcial
 }
 
 type GroupResult struct {
-	Hash      uint64             `json:"hash"`
-	Size      int64              `json:"file_size"`
-	Keeper    *entities.FileInfo `json:"keeper"`
-	Victims   []Victim           `json:"victims"`
-	HardLinks []string           `json:"hardlinks"`
+	Hash       dupes.Digest `json:"hash"`
+	Size       int64        `json:"file_size"`
+	Keeper     *dupes.File  `json:"keeper"`
+	Victims    []Victim     `json:"victims"`
+	HardLinks  []string     `json:"hardlinks"`
+	References []string     `json:"references,omitempty"` // Copias en carpetas de referencia: intocables
+	Archived   []string     `json:"archived,omitempty"`   // Copias dentro de archivos comprimidos: intocables
+	Verified   bool         `json:"byte_verified"`
 }
 
 type Victim struct {
-	Path string `json:"path"`
-	Size int64  `json:"size"`
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	DeviceID uint64    `json:"device_id"`
 }
 
-type sysID struct {
-	dev, inode uint64
+// stringList permite repetir un flag (-dir a -dir b)
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ", ") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// sizeFlag acepta bytes o tamaños legibles (-min-size 100MB)
+type sizeFlag int64
+
+func (s *sizeFlag) String() string { return strconv.FormatInt(int64(*s), 10) }
+
+func (s *sizeFlag) Set(v string) error {
+	n, err := dupes.ParseSize(v)
+	if err != nil {
+		return err
+	}
+	*s = sizeFlag(n)
+	return nil
+}
+
+// splitList expande valores separados por comas (-ext mp4,mkv -ext avi)
+func splitList(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+	}
+	return out
 }
 
 func main() {
+	// Subcomandos
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "restore":
+			runRestore(os.Args[2:])
+			return
+		case "apply":
+			runApply(os.Args[2:])
+			return
+		case "compare":
+			runCompare(os.Args[2:])
+			return
+		}
+	}
+
 	// Flags
-	dirPtr := flag.String("dir", ".", "Directorio a escanear")
-	minSizePtr := flag.Int64("min-size", 1024, "Tamaño mínimo en bytes")
+	var dirs stringList
+	flag.Var(&dirs, "dir", "Directorio a escanear (repetible; también como argumentos posicionales)")
+	var refs stringList
+	flag.Var(&refs, "ref", "🛡️  Directorio de referencia (repetible): puede ser Keeper, nunca se borra")
+	minSize, maxSize := sizeFlag(1024), sizeFlag(0)
+	flag.Var(&minSize, "min-size", "Tamaño mínimo (bytes o 100KB, 1.5GB, 4MiB...)")
+	flag.Var(&maxSize, "max-size", "Tamaño máximo (0 = sin límite)")
+	newerPtr := flag.String("newer-than", "", "📅 Solo archivos modificados hace menos de (30d, 6mo, 1y) o después de (2024-01-31)")
+	olderPtr := flag.String("older-than", "", "📅 Solo archivos modificados hace más de (30d, 6mo, 1y) o antes de (2024-01-31)")
+	var exts, excludeExts stringList
+	flag.Var(&exts, "ext", "Solo estas extensiones (repetible o separadas por comas: mp4,mkv)")
+	flag.Var(&excludeExts, "exclude-ext", "Ignorar estas extensiones (repetible o separadas por comas)")
 	deletePtr := flag.Bool("delete", false, "⚠️  BORRADO NUCLEAR: Elimina archivos inmediatamente")
 	trashPtr := flag.Bool("trash", false, "♻️  SOFT DELETE: Mueve archivos a una carpeta ./TRASH_BIN")
+	xdgTrashPtr := flag.Bool("xdg-trash", false, "♻️  Como -trash, pero usando la papelera del escritorio (freedesktop.org)")
 	keepPtr := flag.String("keep", "shortest", "Criterio: shortest, longest, oldest, newest")
 	jsonPtr := flag.Bool("json", false, "Salida en formato JSON a stdout")
-	outputPtr := flag.String("output", "", "Genera un script .sh")
+	quietPtr := flag.Bool("quiet", false, "🤫 Sin mensajes de progreso (stderr)")
+	outputPtr := flag.String("output", "", "Genera un script .sh (combinable con -link)")
+	linkPtr := flag.String("link", "", "🔗 Reemplaza duplicados por enlaces al keeper: "+LinkHard+", "+LinkReflink+", "+LinkSymlink)
+	journalPtr := flag.String("journal", "", "📒 Ruta del journal de acciones (default: ./dupedetector-journal-FECHA.jsonl)")
+	relativePtr := flag.Bool("relative", false, "Con -link symlink: destino relativo al directorio de la víctima (default: absoluto)")
+	hashPtr := flag.String("hash", dupes.AlgoXXHash, "Algoritmo de hash: "+strings.Join(dupes.Algorithms(), ", "))
+	noCachePtr := flag.Bool("no-cache", false, "No usar la caché persistente de hashes ($XDG_CACHE_HOME/dupedetector)")
+	rehashPtr := flag.Bool("rehash", false, "🔁 Antes de cada acción, re-hashea víctima y Keeper (además de tamaño y mtime)")
+	verifyPtr := flag.Bool("verify", false, "🔬 Fase 4: Comparación byte a byte antes de declarar duplicados")
+	var excludes, includes, excludeRegex, includeRegex stringList
+	flag.Var(&excludes, "exclude", "🚫 Glob a ignorar (repetible): '*.tmp', '**/cache/*', 'build/'")
+	flag.Var(&includes, "include", "✅ Glob de archivos a considerar (repetible): '*.jpg', 'fotos/**'")
+	flag.Var(&excludeRegex, "exclude-regex", "🚫 Regex sobre la ruta relativa a ignorar (repetible)")
+	flag.Var(&includeRegex, "include-regex", "✅ Regex sobre la ruta relativa a considerar (repetible)")
+	followPtr := flag.Bool("follow-symlinks", false, "🔗 Seguir enlaces simbólicos (con detección de bucles). Por defecto se ignoran")
+	oneFSPtr := flag.Bool("one-file-system", false, "💽 No cruzar puntos de montaje (como find -xdev)")
+	archivesPtr := flag.Bool("archives", false, "🗜️  Buscar también dentro de .zip, .tar, .tar.gz y .tar.bz2 (solo lectura: nunca se tocan)")
+	dirsPtr := flag.Bool("dirs", false, "📁 Buscar carpetas enteras idénticas o contenidas en otra (acciones: -trash, -xdg-trash)")
+	gitignorePtr := flag.Bool("gitignore", false, "Respetar también los .gitignore (además de "+dupes.DefaultIgnoreFile+")")
+	noDefaultExcludesPtr := flag.Bool("no-default-excludes", false, "No ignorar "+strings.Join(dupes.DefaultExcludes, ", "))
+
+	positional := parseInterleaved(flag.CommandLine, os.Args[1:])
+
+	// Raíces: -dir repetidos + argumentos posicionales. Por defecto "."
+	roots := append([]string(dirs), positional...)
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
 
-	flag.Parse()
+	// -xdg-trash implica -trash
+	if *xdgTrashPtr {
+		*trashPtr = true
+	}
 
 	// Validación de flags incompatibles
+	// -output se combina con -link (el script emite enlaces en vez de rm)
 	actionCount := 0
 	if *deletePtr { actionCount++ }
 	if *trashPtr { actionCount++ }
-	if *outputPtr != "" { actionCount++ }
+	if *outputPtr != "" && *linkPtr == "" { actionCount++ }
+	if *linkPtr != "" { actionCount++ }
 
 	if actionCount > 1 {
-		fmt.Fprintln(os.Stderr, "❌ Error: Solo puedes elegir UNA acción: -delete, -trash, o -output")
+		fmt.Fprintln(os.Stderr, "❌ Error: Solo puedes elegir UNA acción: -delete, -trash, -link, o -output")
+		os.Exit(1)
+	}
+
+	// Una carpeta entera solo se mueve a la papelera: restore la devuelve tal cual
+	if *dirsPtr && (*deletePtr || *linkPtr != "" || *outputPtr != "") {
+		fmt.Fprintln(os.Stderr, "❌ Error: con -dirs solo se admiten -trash o -xdg-trash")
+		os.Exit(1)
+	}
+	// Con -dirs, un archivo filtrado deja su carpeta incompleta: sin -min-size explícito, se consideran todos
+	if *dirsPtr {
+		minSizeSet := false
+		flag.Visit(func(f *flag.Flag) { minSizeSet = minSizeSet || f.Name == "min-size" })
+		if !minSizeSet {
+			minSize = 0
+		}
+	}
+
+	link := linkConfig{Mode: strings.ToLower(*linkPtr), Relative: *relativePtr}
+	if !validLinkMode(link.Mode) {
+		fmt.Fprintf(os.Stderr, "❌ Modo de enlace desconocido: %s\n", *linkPtr)
 		os.Exit(1)
 	}
 
 	// 1. Configurar Estrategia
-	var strategy engine.KeepStrategy
-	switch strings.ToLower(*keepPtr) {
-	case "shortest": strategy = engine.KeepShortestPath
-	case "longest":  strategy = engine.KeepLongestPath
-	case "oldest":   strategy = engine.KeepOldest
-	case "newest":   strategy = engine.KeepNewest
-	default:
-		fmt.Fprintf(os.Stderr, "❌ Estrategia desconocida: %s\n", *keepPtr)
+	strategy, err := dupes.ParseKeepStrategy(*keepPtr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	// 2. Configurar Filtros (se validan antes de escanear)
+	// TRASH_BIN siempre se excluye: es nuestra propia basura
+	allExcludes := []string{"TRASH_BIN"}
+	if !*noDefaultExcludesPtr {
+		allExcludes = append(allExcludes, dupes.DefaultExcludes...)
+	}
+	allExcludes = append(allExcludes, excludes...)
+	for _, p := range append(append([]string{}, excludes...), includes...) {
+		if err := dupes.ValidatePattern(p); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var newerThan, olderThan time.Time
+	now := time.Now()
+	if *newerPtr != "" {
+		if newerThan, err = dupes.ParseAge(*newerPtr, now); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ -newer-than: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *olderPtr != "" {
+		if olderThan, err = dupes.ParseAge(*olderPtr, now); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ -older-than: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	ignoreFiles := []string{dupes.DefaultIgnoreFile}
+	if *gitignorePtr {
+		ignoreFiles = append(ignoreFiles, ".gitignore")
+	}
+	exRegex, err := compileRegexes(excludeRegex)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ -exclude-regex: %v\n", err)
+		os.Exit(1)
+	}
+	inRegex, err := compileRegexes(includeRegex)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ -include-regex: %v\n", err)
+		os.Exit(1)
+	}
+
+	// 3. Configurar Algoritmo de Hash (también se usa para -rehash)
+	h, err := dupes.NewHasher(*hashPtr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
 		os.Exit(1)
 	}
 
-	// 2. Ejecutar Engine
-	opts := engine.Options{
-		MinSize:  *minSizePtr,
-		Excludes: []string{".git", "node_modules", ".DS_Store", "TRASH_BIN"}, // Excluir nuestra propia basura
-		Strategy: strategy,
+	// 4. Ejecutar la detección (pkg/dupes: sin salida por consola)
+	opts := dupes.Options{
+		MinSize:           int64(minSize),
+		MaxSize:           int64(maxSize),
+		NewerThan:         newerThan,
+		OlderThan:         olderThan,
+		Extensions:        splitList(exts),
+		ExcludeExtensions: splitList(excludeExts),
+		Excludes:          allExcludes,
+		Includes:          includes,
+		ExcludeRegex:      exRegex,
+		IncludeRegex:      inRegex,
+		IgnoreFiles:       ignoreFiles,
+		FollowSymlinks:    *followPtr,
+		OneFileSystem:     *oneFSPtr,
+		Archives:          *archivesPtr,
+		References:        refs,
+		Keep:              strategy,
+		HashAlgorithm:     h.Name(),
+		Verify:            *verifyPtr,
+		UseCache:          !*noCachePtr,
+		Dirs:              *dirsPtr,
+	}
+	// El progreso va siempre a stderr: en modo JSON, stdout queda reservado para el reporte
+	if !*quietPtr {
+		opts.Progress = newConsoleProgress(os.Stderr, opts.UseCache).Handle
 	}
-	runner := engine.New(opts)
 
 	if !*jsonPtr {
-		fmt.Printf("🚀 Dupedetector v1.1 - Escaneando: %s\n", *dirPtr)
+		fmt.Printf("🚀 Dupedetector v1.1 - Escaneando: %s\n", strings.Join(roots, ", "))
 		fmt.Printf("⚖️  Estrategia: Mantener %s\n", strings.ToUpper(*keepPtr))
+		fmt.Printf("🔑 Hash: %s\n", h.Name())
+		if len(refs) > 0 {
+			fmt.Printf("🛡️  Referencias (solo lectura): %s\n", strings.Join(refs, ", "))
+		}
+		if *dirsPtr {
+			fmt.Println("📁 Modo carpetas: árboles idénticos y carpetas contenidas en otra")
+		}
 		fmt.Println("------------------------------------------------")
 	}
 
-	stats, err := runner.Run(*dirPtr)
-	if err != nil {
+	ctx := signalContext()
+	res, err := dupes.Scan(ctx, roots, opts)
+	if err != nil && (res == nil || !res.Interrupted) {
 		die(err, *jsonPtr)
 	}
+	if res.CacheErr != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  %v\n", res.CacheErr)
+	}
 
-	// 3. Generar Reporte
-	report := generateReport(stats, *dirPtr, *keepPtr)
+	// 5. Generar Reporte
+	report := generateReport(res, roots, refs, *keepPtr, *verifyPtr, *archivesPtr, *dirsPtr)
 
-	// 4. Salida
+	// 6. Salida
 	if *jsonPtr {
 		printJSON(report)
-		return
+		os.Exit(exitCode(report))
+	}
+
+	// Interrumpido: se muestra lo encontrado, pero no se actúa ni se genera script
+	if report.Summary.Incomplete {
+		fmt.Println("\n⏹️  Escaneo INTERRUMPIDO: reporte parcial (puede faltar algún duplicado).")
+		processResults(ctx, report, actionConfig{})
+		printErrors(report.Errors)
+		os.Exit(exitCode(report))
 	}
 
 	if *outputPtr != "" {
-		if err := generateShellScript(report, *outputPtr); err != nil {
+		if err := generateShellScript(report, *outputPtr, link); err != nil {
 			fmt.Fprintf(os.Stderr, "❌ Error generando script: %v\n", err)
 			os.Exit(1)
 		}
 		fmt.Printf("\n📄 Script generado: %s\n", *outputPtr)
-		return
+		printErrors(report.Errors)
+		os.Exit(exitCode(report))
+	}
+
+	// Acción Directa (Texto, Delete, Trash o Link)
+	act := actionConfig{
+		Delete: *deletePtr,
+		Trash:  *trashPtr,
+		XDG:    *xdgTrashPtr,
+		Link:   link,
+	}
+	if *rehashPtr {
+		act.Rehash = h
 	}
 
-	// Acción Directa (Texto, Delete o Trash)
-	processResults(report, *deletePtr, *trashPtr)
+	// Journal de acciones: se abre ANTES de tocar nada. Sin journal no hay acción.
+	if err := act.openJournal(*journalPtr); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error creando journal: %v\n", err)
+		os.Exit(1)
+	}
+
+	var interrupted bool
+	if *dirsPtr {
+		interrupted = processDirs(ctx, report, act)
+	} else {
+		interrupted = processResults(ctx, report, act)
+	}
+	if act.Journal != nil {
+		act.Journal.Close()
+	}
+	printErrors(report.Errors)
+	if interrupted {
+		os.Exit(exitInterrupted)
+	}
+	os.Exit(exitCode(report))
+}
+
+// parseInterleaved parsea flags y argumentos posicionales mezclados.
+// flag se detiene en el primer posicional: seguimos parseando para permitir
+// "dupedetector /a /b -trash" o "dupedetector apply reporte.json -trash".
+func parseInterleaved(fs *flag.FlagSet, args []string) []string {
+	_ = fs.Parse(args)
+
+	var positional []string
+	for rest := fs.Args(); len(rest) > 0; rest = fs.Args() {
+		positional = append(positional, rest[0])
+		_ = fs.Parse(rest[1:])
+	}
+	return positional
+}
+
+// signalContext devuelve un contexto que se cancela con Ctrl-C o SIGTERM.
+// Tras la primera señal se restaura el comportamiento por defecto:
+// un segundo Ctrl-C mata el proceso al instante.
+func signalContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-sigs
+		signal.Stop(sigs)
+		fmt.Fprintln(os.Stderr, "\n⏹️  Interrupción recibida: terminando la operación en curso... (Ctrl-C de nuevo para forzar)")
+		cancel()
+	}()
+	return ctx
+}
+
+// compileRegexes compila las expresiones de -exclude-regex / -include-regex.
+func compileRegexes(exprs []string) ([]*regexp.Regexp, error) {
+	out := make([]*regexp.Regexp, 0, len(exprs))
+	for _, e := range exprs {
+		re, err := regexp.Compile(e)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, re)
+	}
+	return out, nil
+}
+
+// actionConfig describe qué hacer con las víctimas. Sin acción activa = Dry Run.
+type actionConfig struct {
+	Delete  bool
+	Trash   bool
+	XDG     bool // Con Trash: papelera freedesktop.org en vez de ./TRASH_BIN
+	Link    linkConfig
+	Journal *Journal
+	Rehash  dupes.Hasher // Re-hash previo a cada acción (nil = solo tamaño y mtime)
+}
+
+// Active indica si se va a modificar el sistema de archivos.
+func (a actionConfig) Active() bool {
+	return a.Delete || a.Trash || a.Link.Mode != ""
+}
+
+// openJournal abre el journal si hay una acción activa (path vacío = nombre por defecto).
+func (a *actionConfig) openJournal(path string) error {
+	if !a.Active() {
+		return nil
+	}
+	if path == "" {
+		path = defaultJournalName()
+	}
+	j, err := OpenJournal(path)
+	if err != nil {
+		return err
+	}
+	a.Journal = j
+	return nil
 }
 
-// processResults maneja la visualización y las acciones inmediatas (delete/trash)
-func processResults(r Report, deleteMode, trashMode bool) {
+// processResults maneja la visualización y las acciones inmediatas (delete/trash/link)
+// Con una acción activa, si ctx se cancela se termina la acción en curso y se para:
+// todo lo hecho queda en el journal.
+// Devuelve true si se interrumpió antes de terminar.
+func processResults(ctx context.Context, r Report, act actionConfig) bool {
 	if len(r.Groups) == 0 {
 		fmt.Println("✅ ¡Limpio! No se encontraron duplicados.")
-		return
+		return false
 	}
 
-	// Preparar carpeta de basura si es necesario
-	trashDir := "TRASH_BIN"
-	if trashMode {
-		if err := os.MkdirAll(trashDir, 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "❌ Error creando carpeta de basura: %v\n", err)
-			os.Exit(1)
-		}
-		fmt.Printf("♻️  Modo Papelera: Los archivos se moverán a ./%s/\n", trashDir)
-	} else if deleteMode {
+	// Preparar papelera si es necesario
+	trash, trashAction := prepareTrash(act, moveToTrash)
+
+	if act.Delete {
 		fmt.Println("🔥 MODO DESTRUCTIVO: Los archivos se borrarán para siempre.")
+	} else if act.Link.Mode != "" {
+		fmt.Printf("🔗 Modo Enlace (%s): Los duplicados se reemplazarán por enlaces al Keeper.\n", act.Link.Mode)
 	}
 
 	fmt.Println("🔴 DUPLICADOS ENCONTRADOS:")
 	actionCount := 0
+	skippedCount := 0
+	var bytesFreed int64
+	interrupted := false
 
+groups:
 	for _, g := range r.Groups {
-		fmt.Printf("   📦 Grupo (Size: %s) | 👑 KEEPER: %s\n", utils.ByteCountDecimal(g.Size), g.Keeper.Path)
+		tags := ""
+		if g.Keeper.Reference {
+			tags += " | 🛡️  Ref"
+		}
+		if g.Keeper.Archive != "" {
+			tags += " | 🗜️  Comprimido"
+		}
+		if g.Verified {
+			tags += " | 🔬 Verificado"
+		}
+		fmt.Printf("   📦 Grupo (Size: %s) | 👑 KEEPER: %s%s\n", dupes.ByteCountDecimal(g.Size), g.Keeper.Path, tags)
 		
 		for _, hl := range g.HardLinks {
 			fmt.Printf("      🔗 [HardLink]: %s (0B)\n", hl)
 		}
 
+		for _, ref := range g.References {
+			fmt.Printf("      🛡️  [Referencia]: %s (intocable)\n", ref)
+		}
+
+		for _, a := range g.Archived {
+			fmt.Printf("      🗜️  [Comprimido]: %s (intocable)\n", a)
+		}
+
+		// Re-verificación: el escaneo pudo ser hace horas y los archivos pueden haber cambiado
+		var check *groupCheck
+		if act.Active() {
+			check = newGroupCheck(g, act.Rehash)
+		}
+
 		for _, v := range g.Victims {
-			if deleteMode {
+			if act.Active() && ctx.Err() != nil {
+				interrupted = true
+				break groups
+			}
+			if check != nil {
+				if err := check.Victim(v); err != nil {
+					fmt.Printf("      ⚠️  Omitido (%v): %s\n", err, v.Path)
+					skippedCount++
+					continue
+				}
+			}
+
+			if act.Delete {
 				// BORRADO NUCLEAR
 				if err := os.Remove(v.Path); err != nil {
 					fmt.Printf("      ❌ Error borrando %s: %v\n", v.Path, err)
 				} else {
 					fmt.Printf("      🔥 Borrado: %s\n", v.Path)
 					actionCount++
+					bytesFreed += v.Size
+					record(act.Journal, ActionDelete, g, v, "")
 				}
-			} else if trashMode {
+			} else if act.Trash {
 				// MOVIMIENTO A PAPELERA
-				if err := moveToTrash(v.Path, trashDir); err != nil {
+				if dest, err := trash(v.Path); err != nil {
 					fmt.Printf("      ❌ Error moviendo %s: %v\n", v.Path, err)
 				} else {
 					fmt.Printf("      ♻️  Movido a basura: %s\n", v.Path)
 					actionCount++
+					bytesFreed += v.Size
+					record(act.Journal, trashAction, g, v, dest)
+				}
+			} else if act.Link.Mode != "" {
+				// REEMPLAZO POR ENLACE
+				if cloned, err := replaceWithLink(act.Link, g.Keeper, v); err == errCrossDevice {
+					fmt.Printf("      ⏭️  Saltado (otro dispositivo): %s\n", v.Path)
+				} else if err == errReflinkUnsupported {
+					fmt.Printf("      ⏭️  Saltado (reflink no soportado aquí): %s\n", v.Path)
+				} else if err != nil {
+					fmt.Printf("      ❌ Error enlazando %s: %v\n", v.Path, err)
+				} else {
+					if cloned {
+						fmt.Printf("      ⚠️  FIDEDUPERANGE no soportado: clonado con FICLONE sin re-verificación del kernel\n")
+					}
+					fmt.Printf("      🔗 Enlazado: %s\n", v.Path)
+					actionCount++
+					bytesFreed += v.Size
+					record(act.Journal, act.Link.Mode, g, v, "")
 				}
 			} else {
 				// DRY RUN
@@ -187,22 +578,81 @@ func processResults(r Report, deleteMode, trashMode bool) {
 	}
 
 	fmt.Println("------------------------------------------------")
-	if deleteMode || trashMode {
+	if interrupted {
+		fmt.Printf("⏹️  Operación INTERRUMPIDA. Archivos procesados: %d (el resto no se tocó)\n", actionCount)
+	} else if act.Active() {
 		fmt.Printf("🏁 Operación completada. Archivos procesados: %d\n", actionCount)
-		fmt.Printf("💾 Espacio liberado: %s\n", r.Summary.BytesSavedHuman)
+	}
+	if act.Active() {
+		if skippedCount > 0 {
+			fmt.Printf("⚠️  Omitidos por cambios desde el escaneo: %d\n", skippedCount)
+		}
+		if act.Journal != nil {
+			fmt.Printf("📒 Journal: %s (deshacer con: dupedetector restore %s)\n", act.Journal.Path(), act.Journal.Path())
+		}
+		fmt.Printf("💾 Espacio liberado: %s\n", dupes.ByteCountDecimal(bytesFreed))
 	} else {
 		fmt.Printf("🏁 Escaneo terminado. Candidatos a borrar: %d\n", r.Summary.TotalDuplicates)
 		fmt.Printf("💾 Espacio recuperable: %s\n", r.Summary.BytesSavedHuman)
+		if r.Summary.TotalArchived > 0 {
+			fmt.Printf("🗜️  Copias dentro de archivos comprimidos: %d (solo informativo)\n", r.Summary.TotalArchived)
+		}
 		fmt.Println("💡 Opciones disponibles:")
 		fmt.Println("   -trash   -> Mover a carpeta segura")
 		fmt.Println("   -output  -> Generar script de revisión")
 		fmt.Println("   -delete  -> Borrar inmediatamente")
+		fmt.Println("   -link    -> Reemplazar por enlaces (hardlink, reflink, symlink)")
+	}
+	return interrupted
+}
+
+// prepareTrash prepara la papelera de -trash/-xdg-trash (sale si no se puede) y
+// devuelve cómo mover una ruta a ella y la acción que se anota en el journal.
+// move se usa con ./TRASH_BIN; la papelera del escritorio mueve archivos y carpetas por igual.
+func prepareTrash(act actionConfig, move func(path, trashDir string) (string, error)) (func(string) (string, error), string) {
+	const trashDir = "TRASH_BIN"
+	if !act.Trash {
+		return nil, ""
+	}
+	if act.XDG {
+		xdg, err := newXDGTrash()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error preparando la papelera del escritorio: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("♻️  Modo Papelera (freedesktop.org): %s (y .Trash-$uid en otros montajes)\n", xdg.Home())
+		return xdg.Move, ActionXDGTrash
+	}
+	if err := os.MkdirAll(trashDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error creando carpeta de basura: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("♻️  Modo Papelera: Se moverán a ./%s/\n", trashDir)
+	return func(path string) (string, error) { return move(path, trashDir) }, ActionTrash
+}
+
+// record registra una acción en el journal (si lo hay). Un fallo aquí se avisa pero
+// la acción ya está hecha: abortar a mitad dejaría el journal aún más incompleto.
+func record(journal *Journal, action string, g GroupResult, v Victim, location string) {
+	if journal == nil {
+		return
+	}
+	err := journal.Record(JournalEntry{
+		Action:   action,
+		Original: v.Path,
+		Location: location,
+		Size:     v.Size,
+		Hash:     g.Hash,
+		Keeper:   g.Keeper.Path,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "      ⚠️  Error escribiendo journal: %v\n", err)
 	}
 }
 
-// moveToTrash mueve el archivo a la carpeta trashDir.
+// moveToTrash mueve el archivo a la carpeta trashDir y devuelve su nueva ruta.
 // Renombra el archivo para evitar colisiones: nombre_TIMESTAMP.ext
-func moveToTrash(srcPath, trashDir string) error {
+func moveToTrash(srcPath, trashDir string) (string, error) {
 	filename := filepath.Base(srcPath)
 	ext := filepath.Ext(filename)
 	nameWithoutExt := strings.TrimSuffix(filename, ext)
@@ -217,11 +667,11 @@ func moveToTrash(srcPath, trashDir string) error {
 		// Si falla (ej: diferentes particiones), hacemos Copy + Remove
 		// Nota: os.Rename falla entre discos distintos.
 		if isCrossDeviceError(err) {
-			return moveCrossDevice(srcPath, destPath)
+			return destPath, moveCrossDevice(srcPath, destPath)
 		}
-		return err
+		return "", err
 	}
-	return nil
+	return destPath, nil
 }
 
 // isCrossDeviceError detecta si el error es "invalid cross-device link"
@@ -230,88 +680,116 @@ func isCrossDeviceError(err error) bool {
 	return strings.Contains(err.Error(), "cross-device") || strings.Contains(err.Error(), "EXDEV")
 }
 
-// moveCrossDevice copia y borra (para mover entre particiones)
+// moveCrossDevice copia y borra (para mover entre particiones), conservando
+// permisos y mtime. Solo archivos regulares: una carpeta o un symlink no se
+// copian (os.Open de una carpeta no falla y dejaría un archivo vacío en dst).
 func moveCrossDevice(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s no es un archivo regular: no se copia entre dispositivos", src)
+	}
+	mode := info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+
 	input, err := os.Open(src)
 	if err != nil {
 		return err
 	}
 	defer input.Close()
 
-	output, err := os.Create(dst)
+	// O_EXCL: nunca pisar un archivo existente en el destino
+	output, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode.Perm())
 	if err != nil {
 		return err
 	}
-	defer output.Close()
-
-	if _, err := io.Copy(output, input); err != nil {
+	_, err = io.Copy(output, input)
+	if cerr := output.Close(); err == nil {
+		err = cerr // Cerrar explícitamente para asegurar flush
+	}
+	if err == nil {
+		// El umask recorta los permisos de OpenFile: se fijan los originales (+x incluido)
+		err = os.Chmod(dst, mode)
+	}
+	if err == nil {
+		err = os.Chtimes(dst, time.Time{}, info.ModTime())
+	}
+	if err != nil {
+		os.Remove(dst) // Nunca dejar una copia a medias
 		return err
 	}
 
-	// Cerrar explícitamente para asegurar flush
-	output.Close()
 	input.Close()
-
 	return os.Remove(src)
 }
 
-// generateReport, generateShellScript, printJSON, die... (MISMOS QUE ANTES)
-func generateReport(stats *engine.Stats, rootDir, strategy string) Report {
+// generateReport convierte el resultado de dupes.Scan al formato de reporte (texto, JSON, apply).
+func generateReport(res *dupes.Result, roots, refs []string, strategy string, verify, archives, dirs bool) Report {
 	rep := Report{
 		Metadata: Metadata{
-			ScannedPath: rootDir,
-			Strategy:    strategy,
-			Timestamp:   time.Now(),
-			Duration:    stats.Duration.String(),
+			ScannedPaths:  roots,
+			References:    refs,
+			Strategy:      strategy,
+			HashAlgorithm: res.HashAlgorithm,
+			ByteVerify:    verify,
+			Archives:      archives,
+			Dirs:          dirs,
+			Timestamp:     time.Now(),
+			Duration:      res.Duration.String(),
 		},
 		Summary: Summary{
-			TotalFilesScanned: stats.TotalFilesScanned,
+			TotalFilesScanned: res.FilesScanned,
+			TotalDuplicates:   res.Duplicates,
+			TotalHardLinks:    res.HardLinks,
+			TotalReferences:   res.ReferenceCopies,
+			TotalArchived:     res.ArchivedCopies,
+			VerifiedGroups:    res.VerifiedGroups,
+			BytesSaved:        res.BytesSaved,
+			BytesSavedHuman:   dupes.ByteCountDecimal(res.BytesSaved),
+			RedundantDirs:     res.RedundantDirs,
+			DirBytesSaved:     res.DirBytesSaved,
+			TotalErrors:       int64(len(res.Errors)),
+			Incomplete:        res.Interrupted,
 		},
 		Groups: []GroupResult{},
+		Dirs:   res.Dirs,
+		Errors: append([]dupes.PathError{}, res.Errors...),
 	}
 
-	for _, group := range stats.FilesByHash {
-		if group.Count < 2 {
-			continue
-		}
-
-		keeper := group.Files[0]
+	for _, g := range res.Groups {
 		gRes := GroupResult{
-			Hash:   keeper.Hash,
-			Size:   keeper.Size,
-			Keeper: keeper,
+			Hash:       g.Hash,
+			Size:       g.Size,
+			Keeper:     g.Keeper,
+			Verified:   g.Verified,
+			HardLinks:  paths(g.HardLinks),
+			References: paths(g.References),
+			Archived:   paths(g.Archived),
 		}
-
-		seenInodes := make(map[sysID]bool)
-		seenInodes[sysID{keeper.DeviceID, keeper.Inode}] = true
-
-		for _, file := range group.Files[1:] {
-			id := sysID{file.DeviceID, file.Inode}
-			
-			if seenInodes[id] {
-				gRes.HardLinks = append(gRes.HardLinks, file.Path)
-				rep.Summary.TotalHardLinks++
-			} else {
-				gRes.Victims = append(gRes.Victims, Victim{
-					Path: file.Path,
-					Size: file.Size,
-				})
-				rep.Summary.TotalDuplicates++
-				rep.Summary.BytesSaved += file.Size
-				seenInodes[id] = true
-			}
-		}
-
-		if len(gRes.Victims) > 0 || len(gRes.HardLinks) > 0 {
-			rep.Groups = append(rep.Groups, gRes)
+		for _, file := range g.Duplicates {
+			gRes.Victims = append(gRes.Victims, Victim{
+				Path:     file.Path,
+				Size:     file.Size,
+				ModTime:  file.ModTime,
+				DeviceID: file.DeviceID,
+			})
 		}
+		rep.Groups = append(rep.Groups, gRes)
 	}
-
-	rep.Summary.BytesSavedHuman = utils.ByteCountDecimal(rep.Summary.BytesSaved)
 	return rep
 }
 
-func generateShellScript(r Report, filename string) error {
+// paths extrae las rutas de una lista de archivos (nil si está vacía, como en el JSON de siempre).
+func paths(files []*dupes.File) []string {
+	var out []string
+	for _, f := range files {
+		out = append(out, f.Path)
+	}
+	return out
+}
+
+func generateShellScript(r Report, filename string, link linkConfig) error {
 	f, err := os.Create(filename)
 	if err != nil {
 		return err
@@ -325,16 +803,84 @@ func generateShellScript(r Report, filename string) error {
 
 	for _, g := range r.Groups {
 		if len(g.Victims) == 0 { continue }
-		fmt.Fprintf(w, "# Group Hash: %x\n", g.Hash)
+		fmt.Fprintf(w, "# Group Hash (%s): %s\n", r.Metadata.HashAlgorithm, g.Hash)
 		fmt.Fprintf(w, "# Keeper: %s\n", g.Keeper.Path)
+		if g.Verified {
+			fmt.Fprintf(w, "# Verificado byte a byte\n")
+		}
+		for _, ref := range g.References {
+			fmt.Fprintf(w, "# Referencia (intocable): %s\n", ref)
+		}
+		for _, a := range g.Archived {
+			fmt.Fprintf(w, "# En archivo comprimido (intocable): %s\n", a)
+		}
 		for _, v := range g.Victims {
-			fmt.Fprintf(w, "rm -v %q\n", v.Path)
+			switch link.Mode {
+			case LinkHard:
+				if v.DeviceID != g.Keeper.DeviceID {
+					fmt.Fprintf(w, "# Saltado (otro dispositivo): %s\n", v.Path)
+					continue
+				}
+				// Enlace temporal + mv: la ruta de la víctima nunca desaparece
+				tmp := tempSibling(v.Path)
+				fmt.Fprintf(w, "ln %q %q && mv -f %q %q\n", g.Keeper.Path, tmp, tmp, v.Path)
+			case LinkReflink:
+				// cp --reflink=always falla (sin copiar) si el FS no soporta CoW
+				tmp := tempSibling(v.Path)
+				fmt.Fprintf(w, "cp --reflink=always %q %q && mv -f %q %q\n", g.Keeper.Path, tmp, tmp, v.Path)
+			case LinkSymlink:
+				target, err := symlinkTarget(g.Keeper.Path, v.Path, link.Relative)
+				if err != nil {
+					return err
+				}
+				tmp := tempSibling(v.Path)
+				fmt.Fprintf(w, "ln -s %q %q && mv -f %q %q\n", target, tmp, tmp, v.Path)
+			default:
+				fmt.Fprintf(w, "rm -v %q\n", v.Path)
+			}
 		}
 		fmt.Fprintf(w, "\n")
 	}
 	return w.Flush()
 }
 
+// printErrors resume las rutas que no se pudieron leer (el detalle completo va en -json).
+func printErrors(errs []dupes.PathError) {
+	if len(errs) == 0 {
+		return
+	}
+	const maxShown = 10
+
+	fmt.Fprintf(os.Stderr, "\n⚠️  %d rutas no se pudieron leer: el escaneo está INCOMPLETO\n", len(errs))
+	byKind := make(map[dupes.ErrorKind]int)
+	for _, e := range errs {
+		byKind[e.Kind]++
+	}
+	for _, kind := range []dupes.ErrorKind{dupes.ErrPermission, dupes.ErrNotFound, dupes.ErrIO, dupes.ErrOther} {
+		if n := byKind[kind]; n > 0 {
+			fmt.Fprintf(os.Stderr, "   %s: %d\n", kind, n)
+		}
+	}
+	for i, e := range errs {
+		if i == maxShown {
+			fmt.Fprintf(os.Stderr, "   ... y %d más (detalle completo con -json)\n", len(errs)-maxShown)
+			break
+		}
+		fmt.Fprintf(os.Stderr, "   [%s] %s: %s\n", e.Phase, e.Path, e.Kind)
+	}
+}
+
+// exitCode devuelve exitInterrupted si hubo cancelación y exitIncomplete si hubo archivos sin leer.
+func exitCode(r Report) int {
+	if r.Summary.Incomplete {
+		return exitInterrupted
+	}
+	if len(r.Errors) > 0 {
+		return exitIncomplete
+	}
+	return 0
+}
+
 func printJSON(r Report) {
 	enc := json.NewEncoder(os.Stdout)
 	enc.SetIndent("", "  ")
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMoveCrossDevice(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	src := writeFile(t, dir, "script.sh", "#!/bin/sh\n")
+	if err := os.Chmod(src, 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(dir, "movido.sh")
+	if err := moveCrossDevice(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0750 || !info.ModTime().Equal(mtime) {
+		t.Errorf("modo %v, mtime %v; quería 0750 y %v", info.Mode().Perm(), info.ModTime(), mtime)
+	}
+	if readFile(t, dst) != "#!/bin/sh\n" {
+		t.Error("contenido distinto")
+	}
+	if _, err := os.Lstat(src); err == nil {
+		t.Error("el origen sigue existiendo")
+	}
+
+	// Nunca pisa un destino existente
+	other := writeFile(t, dir, "otro.sh", "otro")
+	if err := moveCrossDevice(other, dst); err == nil || readFile(t, dst) != "#!/bin/sh\n" {
+		t.Errorf("sobrescribió el destino (err = %v)", err)
+	}
+}
+
+// Una carpeta (p. ej. una restaurada desde la papelera) se rechaza sin dejar nada en el destino.
+func TestMoveCrossDeviceDir(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "carpeta")
+	writeFile(t, src, "a.txt", "a")
+
+	dst := filepath.Join(dir, "destino")
+	if err := moveCrossDevice(src, dst); err == nil {
+		t.Fatal("moveCrossDevice movió una carpeta")
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		t.Error("quedó algo en el destino")
+	}
+	if readFile(t, filepath.Join(src, "a.txt")) != "a" {
+		t.Error("el origen cambió")
+	}
+}
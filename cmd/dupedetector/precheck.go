@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/soyunomas/dupedetector/pkg/dupes"
+)
+
+// verifyUnchanged comprueba, justo antes de actuar, que path sigue siendo el
+// mismo archivo regular que se escaneó: mismo tamaño, mismo mtime (si se conoce)
+// y, si h no es nil, mismo hash. Devuelve los stats actuales del archivo.
+func verifyUnchanged(path string, size int64, modTime time.Time, hash dupes.Digest, h dupes.Hasher) (dupes.FileStats, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return dupes.FileStats{}, errors.New("ya no existe")
+	}
+	if !info.Mode().IsRegular() {
+		return dupes.FileStats{}, errors.New("ya no es un archivo regular")
+	}
+	if info.Size() != size {
+		return dupes.FileStats{}, fmt.Errorf("el tamaño cambió (%d -> %d bytes)", size, info.Size())
+	}
+	// Reportes antiguos no traen mtime: en ese caso solo cuentan tamaño y hash
+	if !modTime.IsZero() && !info.ModTime().Equal(modTime) {
+		return dupes.FileStats{}, errors.New("fue modificado desde el escaneo")
+	}
+
+	if h == nil {
+		stats := dupes.FileStats{Size: info.Size()}
+		stats.DeviceID, stats.Inode = sysIDOf(info)
+		return stats, nil
+	}
+
+	// La re-verificación es parte de la acción en curso: se completa aunque llegue un Ctrl-C
+	digest, stats, err := h.HashFile(context.Background(), dupes.OS, path, nil)
+	if err != nil {
+		return dupes.FileStats{}, err
+	}
+	if digest != hash {
+		return dupes.FileStats{}, errors.New("el contenido cambió desde el escaneo")
+	}
+	return stats, nil
+}
+
+// groupCheck re-verifica el Keeper de un grupo una sola vez (el re-hash es caro)
+// y después, antes de cada víctima, que el Keeper siga existiendo.
+type groupCheck struct {
+	g        GroupResult
+	h        dupes.Hasher
+	keeper   dupes.FileStats
+	keeperOK error
+}
+
+func newGroupCheck(g GroupResult, h dupes.Hasher) *groupCheck {
+	c := &groupCheck{g: g, h: h}
+	c.keeper, c.keeperOK = verifyUnchanged(g.Keeper.Path, g.Keeper.Size, g.Keeper.ModTime, g.Hash, h)
+	return c
+}
+
+// Victim devuelve nil si es seguro actuar sobre v.
+func (c *groupCheck) Victim(v Victim) error {
+	if c.keeperOK != nil {
+		return fmt.Errorf("Keeper %s %v", c.g.Keeper.Path, c.keeperOK)
+	}
+	// Barato: el Keeper pudo desaparecer mientras procesábamos el grupo
+	if _, err := os.Lstat(c.g.Keeper.Path); err != nil {
+		return fmt.Errorf("Keeper %s ya no existe", c.g.Keeper.Path)
+	}
+
+	stats, err := verifyUnchanged(v.Path, v.Size, v.ModTime, c.g.Hash, c.h)
+	if err != nil {
+		return err
+	}
+	if stats.Inode != 0 && stats.DeviceID == c.keeper.DeviceID && stats.Inode == c.keeper.Inode {
+		return errors.New("ya es un hard link del Keeper")
+	}
+	return nil
+}
+
+// sysIDOf extrae DeviceID e Inode de un os.FileInfo (0, 0 si no hay Stat_t).
+func sysIDOf(info os.FileInfo) (uint64, uint64) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+	return uint64(stat.Dev), uint64(stat.Ino)
+}
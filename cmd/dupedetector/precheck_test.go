@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/soyunomas/dupedetector/pkg/dupes"
+)
+
+func TestGroupCheck(t *testing.T) {
+	h, err := dupes.NewHasher("xxhash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		rehash bool
+		change func(t *testing.T, keeper, victim string)
+		ok     bool
+	}{
+		{"sin cambios", false, func(t *testing.T, k, v string) {}, true},
+		{"sin cambios, re-hash", true, func(t *testing.T, k, v string) {}, true},
+		{"keeper con otro mtime", false, func(t *testing.T, k, v string) {
+			if err := os.Chtimes(k, later, later); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+		{"keeper con otro tamaño", false, func(t *testing.T, k, v string) {
+			if err := os.WriteFile(k, []byte("DATOS"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+		{"keeper desaparecido", false, func(t *testing.T, k, v string) { os.Remove(k) }, false},
+		{"víctima con otro mtime", false, func(t *testing.T, k, v string) {
+			if err := os.Chtimes(v, later, later); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+		{"víctima reemplazada por symlink", false, func(t *testing.T, k, v string) {
+			os.Remove(v)
+			if err := os.Symlink(k, v); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			keeper := writeFile(t, dir, "keeper.txt", "DATOS123")
+			victim := writeFile(t, dir, "victim.txt", "DATOS123")
+			digest, _, err := h.HashFile(context.Background(), dupes.OS, keeper, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			g := GroupResult{
+				Hash:    digest,
+				Size:    8,
+				Keeper:  &dupes.File{Path: keeper, Size: 8, ModTime: modTime(t, keeper)},
+				Victims: []Victim{{Path: victim, Size: 8, ModTime: modTime(t, victim)}},
+			}
+
+			tt.change(t, keeper, victim)
+			var rehash dupes.Hasher
+			if tt.rehash {
+				rehash = h
+			}
+			err = newGroupCheck(g, rehash).Victim(g.Victims[0])
+			if (err == nil) != tt.ok {
+				t.Errorf("Victim() = %v, quería ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+// Con -rehash un cambio de contenido que conserva tamaño y mtime también aborta.
+func TestGroupCheckRehashKeeper(t *testing.T) {
+	h, err := dupes.NewHasher("xxhash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	keeper := writeFile(t, dir, "keeper.txt", "DATOS123")
+	victim := writeFile(t, dir, "victim.txt", "DATOS123")
+	digest, _, err := h.HashFile(context.Background(), dupes.OS, keeper, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mtime := modTime(t, keeper)
+	writeFile(t, dir, "keeper.txt", "OTROS123")
+	if err := os.Chtimes(keeper, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	g := GroupResult{
+		Hash:   digest,
+		Size:   8,
+		Keeper: &dupes.File{Path: keeper, Size: 8, ModTime: mtime},
+	}
+	v := Victim{Path: victim, Size: 8, ModTime: modTime(t, victim)}
+	if err := newGroupCheck(g, nil).Victim(v); err != nil {
+		t.Fatalf("sin -rehash el cambio no es visible, pero falló: %v", err)
+	}
+	if err := newGroupCheck(g, h).Victim(v); err == nil {
+		t.Error("con -rehash se aceptó un Keeper con otro contenido")
+	}
+}
+
+// modTime devuelve el mtime actual de p.
+func modTime(t *testing.T, p string) time.Time {
+	t.Helper()
+	info, err := os.Lstat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/soyunomas/dupedetector/pkg/dupes"
+)
+
+// Cada cuánto se redibuja la barra (TTY) o se escribe una línea de log (no TTY).
+const (
+	barRefresh = 100 * time.Millisecond
+	logRefresh = 5 * time.Second
+	barWidth   = 25
+)
+
+// consoleProgress pinta los eventos del engine (por defecto en stderr, para que
+// stdout quede limpio para el reporte o el JSON). En una terminal dibuja una
+// barra con bytes, velocidad y ETA; si no (CI, redirección a archivo), escribe
+// líneas de log periódicas.
+type consoleProgress struct {
+	w     io.Writer
+	tty   bool
+	cache bool // Mostrar aciertos de caché al final de cada fase
+
+	phaseStart time.Time
+	lastDraw   time.Time
+	drawn      bool // Hay una barra a medio dibujar en la línea actual
+	last       dupes.Event
+}
+
+func newConsoleProgress(f *os.File, cache bool) *consoleProgress {
+	return &consoleProgress{w: f, tty: isTerminal(f), cache: cache}
+}
+
+// isTerminal indica si f es una terminal (sin dependencias: basta con el modo del archivo).
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// phaseTitles son los mensajes de inicio de cada fase.
+var phaseTitles = map[dupes.Phase]string{
+	dupes.PhaseScan:    "🔍 Fase 1: Escaneando sistema de archivos...",
+	dupes.PhasePreHash: "🔍 Fase 2: Pre-Hashing (4KB check)...",
+	dupes.PhaseHash:    "🔍 Fase 3: Hashing Completo (Verificación final)...",
+	dupes.PhaseVerify:  "🔍 Fase 4: Verificación byte a byte contra el Keeper...",
+}
+
+func (p *consoleProgress) Handle(e dupes.Event) {
+	switch e.Kind {
+	case dupes.EventPhaseStarted:
+		p.clear()
+		fmt.Fprintln(p.w, phaseTitles[e.Phase])
+		p.phaseStart = time.Now()
+		p.lastDraw = p.phaseStart
+		p.last = e
+
+	case dupes.EventRootStarted:
+		p.clear()
+		fmt.Fprintf(p.w, "🔍 Iniciando escaneo en: %s\n", e.Path)
+
+	case dupes.EventFilesDiscovered:
+		// El scanner no conoce el total: solo se cuentan archivos y bytes
+		p.last.Files = e.Files
+		p.last.Bytes += e.Bytes
+		p.tick()
+
+	case dupes.EventProgress:
+		p.last = e
+		p.tick()
+
+	case dupes.EventPhaseFinished:
+		if p.drawn {
+			p.draw() // Estado final de la barra antes del resumen
+			fmt.Fprintln(p.w)
+			p.drawn = false
+		}
+		p.phaseFinished(e)
+	}
+}
+
+// tick redibuja si pasó suficiente tiempo desde la última vez.
+func (p *consoleProgress) tick() {
+	now := time.Now()
+	every := logRefresh
+	if p.tty {
+		every = barRefresh
+	}
+	if now.Sub(p.lastDraw) < every {
+		return
+	}
+	p.lastDraw = now
+	p.draw()
+}
+
+// draw pinta el estado actual: barra en la misma línea (TTY) o una línea de log.
+func (p *consoleProgress) draw() {
+	line := p.status()
+	if p.tty {
+		fmt.Fprintf(p.w, "\r\033[K   %s", line)
+		p.drawn = true
+	} else {
+		fmt.Fprintf(p.w, "   [%s] %s\n", p.last.Phase, line)
+	}
+}
+
+// clear borra la barra a medio dibujar (solo TTY).
+func (p *consoleProgress) clear() {
+	if p.drawn {
+		fmt.Fprint(p.w, "\r\033[K")
+		p.drawn = false
+	}
+}
+
+// status arma el texto de progreso: "[#####-----] 45% 1234/5000 | 1.2 GB/2.7 GB | 150.3 MB/s | ETA 42s"
+func (p *consoleProgress) status() string {
+	e := p.last
+	elapsed := time.Since(p.phaseStart)
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(e.Bytes) / elapsed.Seconds()
+	}
+
+	// Fase de escaneo: no hay total conocido
+	if e.Total == 0 {
+		return fmt.Sprintf("%d archivos | %s", e.Files, dupes.ByteCountDecimal(e.Bytes))
+	}
+
+	frac := 0.0
+	if e.TotalBytes > 0 {
+		frac = float64(e.Bytes) / float64(e.TotalBytes)
+	} else {
+		frac = float64(e.Files) / float64(e.Total)
+	}
+	frac = min(max(frac, 0), 1)
+
+	eta := "--"
+	if rate > 0 && e.TotalBytes > e.Bytes {
+		eta = formatETA(time.Duration(float64(e.TotalBytes-e.Bytes) / rate * float64(time.Second)))
+	} else if e.Bytes >= e.TotalBytes {
+		eta = "0s"
+	}
+
+	text := fmt.Sprintf("%3.0f%% %d/%d | %s/%s | %s/s | ETA %s",
+		frac*100, e.Files, e.Total,
+		dupes.ByteCountDecimal(e.Bytes), dupes.ByteCountDecimal(e.TotalBytes),
+		dupes.ByteCountDecimal(int64(rate)), eta)
+	if !p.tty {
+		return text
+	}
+	filled := int(frac * barWidth)
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", barWidth-filled) + "] " + text
+}
+
+// formatETA: "1h02m", "3m05s", "42s".
+func formatETA(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+}
+
+func (p *consoleProgress) phaseFinished(e dupes.Event) {
+	switch e.Phase {
+	case dupes.PhaseScan:
+		fmt.Fprintf(p.w, "   -> %d archivos encontrados. %d candidatos por tamaño.\n", e.Files, e.Candidates)
+	case dupes.PhasePreHash:
+		fmt.Fprintf(p.w, "   -> %d candidatos tras Pre-Hash.%s\n", e.Candidates, p.cacheNote(e))
+	case dupes.PhaseHash:
+		if e.Interrupted {
+			fmt.Fprintf(p.w, "   -> Hashing interrumpido.%s\n", p.cacheNote(e))
+		} else {
+			fmt.Fprintf(p.w, "   -> Hashing terminado en %s.%s\n", time.Since(p.phaseStart).Round(time.Millisecond), p.cacheNote(e))
+		}
+	case dupes.PhaseVerify:
+		fmt.Fprintf(p.w, "   -> %d grupos verificados (%d divididos por colisión de hash).\n", e.Candidates, e.Splits)
+	}
+}
+
+// cacheNote añade el número de aciertos de caché a los mensajes de fase.
+func (p *consoleProgress) cacheNote(e dupes.Event) string {
+	if !p.cache {
+		return ""
+	}
+	return fmt.Sprintf(" (%d desde caché)", e.CacheHits)
+}
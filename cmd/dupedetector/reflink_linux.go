@@ -0,0 +1,132 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxDedupeChunk: el kernel limita cada petición FIDEDUPERANGE (btrfs: 16MiB).
+const maxDedupeChunk = 16 * 1024 * 1024
+
+// replaceWithReflink hace que la víctima comparta extents con el keeper.
+// Primero intenta FIDEDUPERANGE sobre la víctima existente: el kernel re-verifica
+// el contenido y la víctima conserva su inodo y metadatos. Solo si el sistema de
+// archivos no implementa FIDEDUPERANGE, clona el keeper en un temporal y lo
+// renombra encima (cloned = true: sin la comparación del kernel).
+func replaceWithReflink(keeperPath, victimPath string) (cloned bool, err error) {
+	err = dedupeRange(keeperPath, victimPath)
+	if errors.Is(err, errReflinkUnsupported) {
+		return true, cloneOver(keeperPath, victimPath)
+	}
+	return false, err
+}
+
+// dedupeRange comparte los extents del keeper con la víctima, bloque a bloque.
+func dedupeRange(keeperPath, victimPath string) error {
+	src, err := os.Open(keeperPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// El destino debe abrirse con permiso de escritura (no modifica su contenido ni mtime)
+	dst, err := os.OpenFile(victimPath, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	size := uint64(info.Size())
+	var offset uint64
+	for offset < size {
+		length := size - offset
+		if length > maxDedupeChunk {
+			length = maxDedupeChunk
+		}
+
+		req := unix.FileDedupeRange{
+			Src_offset: offset,
+			Src_length: length,
+			Info: []unix.FileDedupeRangeInfo{{
+				Dest_fd:     int64(dst.Fd()),
+				Dest_offset: offset,
+			}},
+		}
+		if err := unix.IoctlFileDedupeRange(int(src.Fd()), &req); err != nil {
+			return mapReflinkError(err)
+		}
+
+		res := req.Info[0]
+		switch {
+		case res.Status == unix.FILE_DEDUPE_RANGE_DIFFERS:
+			return errContentDiffers
+		case res.Status < 0:
+			return mapReflinkError(unix.Errno(-res.Status))
+		case res.Bytes_deduped == 0:
+			// Sin avance: el kernel rechazó el rango, no es "no soportado"
+			return fmt.Errorf("FIDEDUPERANGE no compartió ningún byte en el offset %d", offset)
+		}
+		offset += res.Bytes_deduped
+	}
+	return nil
+}
+
+// cloneOver clona el keeper completo (FICLONE) en un temporal junto a la víctima
+// y lo renombra encima, conservando permisos y mtime de la víctima.
+func cloneOver(keeperPath, victimPath string) error {
+	victimInfo, err := os.Stat(victimPath)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(keeperPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := tempSibling(victimPath)
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, victimInfo.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if err := unix.IoctlFileClone(int(dst.Fd()), int(src.Fd())); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return mapReflinkError(err)
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	_ = os.Chtimes(tmp, victimInfo.ModTime(), victimInfo.ModTime())
+	if err := os.Rename(tmp, victimPath); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// mapReflinkError traduce los errno de "no soportado" a errores propios.
+// EINVAL no cuenta: suele ser un rango o un archivo que el kernel rechaza.
+func mapReflinkError(err error) error {
+	switch {
+	case errors.Is(err, unix.EXDEV):
+		return errCrossDevice
+	case errors.Is(err, unix.EOPNOTSUPP), errors.Is(err, unix.ENOTTY), errors.Is(err, unix.ENOSYS):
+		return errReflinkUnsupported
+	}
+	return err
+}
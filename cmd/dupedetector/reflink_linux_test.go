@@ -0,0 +1,35 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// Solo "no implementado" lleva a FICLONE: un rechazo del kernel nunca se clona encima.
+func TestMapReflinkError(t *testing.T) {
+	tests := []struct {
+		errno unix.Errno
+		want  error // nil: el error se devuelve tal cual
+	}{
+		{unix.EOPNOTSUPP, errReflinkUnsupported},
+		{unix.ENOTTY, errReflinkUnsupported},
+		{unix.ENOSYS, errReflinkUnsupported},
+		{unix.EXDEV, errCrossDevice},
+		{unix.EINVAL, nil},
+		{unix.EPERM, nil},
+	}
+	for _, tt := range tests {
+		err := mapReflinkError(fmt.Errorf("ioctl: %w", tt.errno))
+		switch {
+		case tt.want != nil && !errors.Is(err, tt.want):
+			t.Errorf("%v -> %v, quería %v", tt.errno, err, tt.want)
+		case tt.want == nil && (errors.Is(err, errReflinkUnsupported) || !errors.Is(err, tt.errno)):
+			t.Errorf("%v -> %v, quería el errno original", tt.errno, err)
+		}
+	}
+}
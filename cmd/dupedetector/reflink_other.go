@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+// replaceWithReflink: FICLONE/FIDEDUPERANGE solo existen en Linux.
+func replaceWithReflink(keeperPath, victimPath string) (cloned bool, err error) {
+	return false, errReflinkUnsupported
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// runRestore implementa `dupedetector restore <journal>`.
+// Deshace las acciones en orden inverso y nunca sobrescribe un archivo
+// que haya reaparecido en la ruta original.
+func runRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dryRunPtr := fs.Bool("dry-run", false, "Muestra qué se restauraría sin mover nada")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Uso: dupedetector restore [-dry-run] <journal>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	entries, err := ReadJournal(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error leyendo journal: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("⏪ Restaurando desde: %s (%d acciones)\n", fs.Arg(0), len(entries))
+	fmt.Println("------------------------------------------------")
+
+	restored, skipped, failed := restoreEntries(entries, *dryRunPtr)
+
+	fmt.Println("------------------------------------------------")
+	fmt.Printf("🏁 Restaurados: %d | Omitidos: %d | Errores: %d\n", restored, skipped, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// restoreEntries deshace las entradas del journal, de la última a la primera.
+func restoreEntries(entries []JournalEntry, dryRun bool) (restored, skipped, failed int) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+
+		if e.Action != ActionTrash && e.Action != ActionXDGTrash {
+			fmt.Printf("   ⏭️  No reversible (%s): %s\n", e.Action, e.Original)
+			skipped++
+			continue
+		}
+
+		// Algo reapareció en la ruta original: no lo tocamos
+		if _, err := os.Lstat(e.Original); err == nil {
+			fmt.Printf("   ⚠️  Ya existe, no se sobrescribe: %s\n", e.Original)
+			skipped++
+			continue
+		}
+		if _, err := os.Lstat(e.Location); err != nil {
+			fmt.Printf("   ❌ No se encuentra en la papelera: %s\n", e.Location)
+			failed++
+			continue
+		}
+
+		if dryRun {
+			fmt.Printf("   ⏪ [Dry Run] %s -> %s\n", e.Location, e.Original)
+			restored++
+			continue
+		}
+
+		if err := restoreFile(e.Location, e.Original); err != nil {
+			fmt.Printf("   ❌ Error restaurando %s: %v\n", e.Original, err)
+			failed++
+			continue
+		}
+		if e.Action == ActionXDGTrash {
+			// Sin su .trashinfo, el gestor de archivos ya no lo listaría
+			os.Remove(trashInfoPath(e.Location))
+		}
+		fmt.Printf("   ⏪ Restaurado: %s\n", e.Original)
+		restored++
+	}
+	return restored, skipped, failed
+}
+
+// restoreFile devuelve un archivo a su ruta original, recreando el directorio si hace falta.
+func restoreFile(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	// Link + Remove en vez de Rename: Link falla si el destino existe (sin carreras)
+	err := os.Link(from, to)
+	switch {
+	case err == nil:
+		return os.Remove(from)
+	case os.IsExist(err):
+		return err
+	case isCrossDeviceError(err):
+		// Copia conservando permisos y fechas; una carpeta se rechaza sin crear nada
+		return moveCrossDevice(from, to)
+	}
+	// Sistemas de archivos sin hard links (vfat...): Rename tras el Lstat previo
+	return os.Rename(from, to)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// trashWithJournal mueve files a dir/TRASH_BIN registrando cada acción, y
+// devuelve las entradas tal como las lee restore.
+func trashWithJournal(t *testing.T, dir string, files ...string) []JournalEntry {
+	t.Helper()
+	trash := filepath.Join(dir, "TRASH_BIN")
+	if err := os.MkdirAll(trash, 0755); err != nil {
+		t.Fatal(err)
+	}
+	journalPath := filepath.Join(dir, "journal.jsonl")
+	j, err := OpenJournal(journalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loc, err := moveToTrash(f, trash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := j.Record(JournalEntry{Action: ActionTrash, Original: f, Location: loc, Size: info.Size(), Hash: "h", Keeper: "keeper"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Un segundo journal en la misma ruta no se mezcla con el anterior
+	if _, err := OpenJournal(journalPath); err == nil {
+		t.Error("OpenJournal sobre un journal existente no falló")
+	}
+
+	entries, err := ReadJournal(journalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(files) {
+		t.Fatalf("%d entradas, quería %d", len(entries), len(files))
+	}
+	return entries
+}
+
+func TestRestoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "fotos/a.jpg", "AAAA")
+	b := writeFile(t, dir, "fotos/viaje/b.jpg", "BBBB")
+	entries := trashWithJournal(t, dir, a, b)
+
+	for i, e := range entries {
+		if e.Original != []string{a, b}[i] || e.Action != ActionTrash || e.Timestamp.IsZero() {
+			t.Errorf("entrada %d = %+v", i, e)
+		}
+	}
+	// La carpeta vacía también desaparece: restore la recrea
+	if err := os.Remove(filepath.Join(dir, "fotos", "viaje")); err != nil {
+		t.Fatal(err)
+	}
+
+	// -dry-run no mueve nada
+	if restored, skipped, failed := restoreEntries(entries, true); restored != 2 || skipped != 0 || failed != 0 {
+		t.Fatalf("dry-run: %d/%d/%d", restored, skipped, failed)
+	}
+	if _, err := os.Lstat(a); err == nil {
+		t.Fatal("-dry-run restauró un archivo")
+	}
+
+	if restored, skipped, failed := restoreEntries(entries, false); restored != 2 || skipped != 0 || failed != 0 {
+		t.Fatalf("restaurados/omitidos/errores = %d/%d/%d, quería 2/0/0", restored, skipped, failed)
+	}
+	if readFile(t, a) != "AAAA" || readFile(t, b) != "BBBB" {
+		t.Error("contenido restaurado distinto")
+	}
+	onlyFiles(t, filepath.Join(dir, "TRASH_BIN"))
+}
+
+// Lo que reaparece en la ruta original nunca se sobrescribe, y la copia sigue en la papelera.
+func TestRestoreRefusesExisting(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "a.txt", "original")
+	entries := trashWithJournal(t, dir, a)
+	writeFile(t, dir, "a.txt", "nuevo")
+
+	if restored, skipped, failed := restoreEntries(entries, false); restored != 0 || skipped != 1 || failed != 0 {
+		t.Fatalf("restaurados/omitidos/errores = %d/%d/%d, quería 0/1/0", restored, skipped, failed)
+	}
+	if got := readFile(t, a); got != "nuevo" {
+		t.Errorf("se sobrescribió el archivo nuevo: %q", got)
+	}
+	if got := readFile(t, entries[0].Location); got != "original" {
+		t.Errorf("la papelera perdió la copia: %q", got)
+	}
+
+	// Aunque aparezca entre el Lstat y el movimiento, restoreFile no pisa nada
+	if err := restoreFile(entries[0].Location, a); err == nil {
+		t.Error("restoreFile sobrescribió un destino existente")
+	}
+}
+
+func TestRestoreSkipsIrreversible(t *testing.T) {
+	entries := []JournalEntry{{Action: ActionDelete, Original: filepath.Join(t.TempDir(), "x")}}
+	if restored, skipped, failed := restoreEntries(entries, false); restored != 0 || skipped != 1 || failed != 0 {
+		t.Errorf("restaurados/omitidos/errores = %d/%d/%d, quería 0/1/0", restored, skipped, failed)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// xdgTrash implementa la especificación freedesktop.org Trash:
+// $XDG_DATA_HOME/Trash para el dispositivo del home, y $topdir/.Trash/$uid
+// o $topdir/.Trash-$uid para el resto de montajes. Nunca copia entre dispositivos.
+type xdgTrash struct {
+	home  string                   // $XDG_DATA_HOME/Trash
+	byDev map[uint64]trashLocation // Papelera elegida para cada dispositivo
+	uid   string
+}
+
+// trashLocation es una papelera concreta. topdir solo se usa en las de montaje,
+// donde la ruta original se guarda relativa a él.
+type trashLocation struct {
+	dir    string
+	topdir string
+}
+
+// newXDGTrash localiza (y crea si hace falta) la papelera del usuario.
+func newXDGTrash() (*xdgTrash, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+
+	t := &xdgTrash{
+		home:  filepath.Join(dataHome, "Trash"),
+		byDev: make(map[uint64]trashLocation),
+		uid:   strconv.Itoa(os.Getuid()),
+	}
+	if err := ensureTrashDirs(t.home); err != nil {
+		return nil, err
+	}
+	dev, err := deviceOf(t.home)
+	if err != nil {
+		return nil, err
+	}
+	t.byDev[dev] = trashLocation{dir: t.home}
+	return t, nil
+}
+
+// Home devuelve la papelera principal del usuario.
+func (t *xdgTrash) Home() string {
+	return t.home
+}
+
+// Move mueve path a la papelera de su dispositivo y escribe el .trashinfo.
+// Devuelve la nueva ubicación (dentro de files/).
+func (t *xdgTrash) Move(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	dev, err := deviceOf(abs)
+	if err != nil {
+		return "", err
+	}
+
+	loc, err := t.trashFor(abs, dev)
+	if err != nil {
+		return "", err
+	}
+
+	// En las papeleras de montaje la ruta se guarda relativa al $topdir
+	origPath := abs
+	if loc.topdir != "" {
+		if rel, err := filepath.Rel(loc.topdir, abs); err == nil {
+			origPath = rel
+		}
+	}
+
+	// Reservamos el nombre creando primero el .trashinfo con O_EXCL (exigido por la spec)
+	name, infoPath, err := reserveTrashName(loc.dir, filepath.Base(abs), origPath)
+	if err != nil {
+		return "", err
+	}
+
+	dest := filepath.Join(loc.dir, "files", name)
+	if err := os.Rename(abs, dest); err != nil {
+		os.Remove(infoPath)
+		return "", err
+	}
+	return dest, nil
+}
+
+// trashFor elige la papelera para un dispositivo según la spec.
+func (t *xdgTrash) trashFor(path string, dev uint64) (trashLocation, error) {
+	if loc, ok := t.byDev[dev]; ok {
+		return loc, nil
+	}
+
+	topdir, err := mountPoint(path, dev)
+	if err != nil {
+		return trashLocation{}, err
+	}
+
+	// 1. $topdir/.Trash compartida: debe ser directorio real (no symlink) con sticky bit
+	if info, err := os.Lstat(filepath.Join(topdir, ".Trash")); err == nil &&
+		info.IsDir() && info.Mode()&os.ModeSticky != 0 {
+		loc := trashLocation{dir: filepath.Join(topdir, ".Trash", t.uid), topdir: topdir}
+		if err := ensureTrashDirs(loc.dir); err == nil {
+			t.byDev[dev] = loc
+			return loc, nil
+		}
+	}
+
+	// 2. $topdir/.Trash-$uid
+	loc := trashLocation{dir: filepath.Join(topdir, ".Trash-"+t.uid), topdir: topdir}
+	if err := ensureTrashDirs(loc.dir); err != nil {
+		return trashLocation{}, fmt.Errorf("sin papelera en %s: %w", topdir, err)
+	}
+	t.byDev[dev] = loc
+	return loc, nil
+}
+
+// reserveTrashName crea info/NOMBRE.trashinfo de forma atómica, probando
+// NOMBRE, NOMBRE.2, NOMBRE.3... hasta encontrar uno libre.
+func reserveTrashName(trashDir, base, origPath string) (string, string, error) {
+	content := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: origPath}).EscapedPath(),
+		time.Now().Format("2006-01-02T15:04:05"))
+
+	for i := 1; ; i++ {
+		name := base
+		if i > 1 {
+			name = fmt.Sprintf("%s.%d", base, i)
+		}
+		infoPath := filepath.Join(trashDir, "info", name+".trashinfo")
+
+		f, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", "", err
+		}
+
+		// El nombre también debe estar libre en files/
+		if _, err := os.Lstat(filepath.Join(trashDir, "files", name)); err == nil {
+			f.Close()
+			os.Remove(infoPath)
+			continue
+		}
+
+		if _, err := f.WriteString(content); err != nil {
+			f.Close()
+			os.Remove(infoPath)
+			return "", "", err
+		}
+		if err := f.Close(); err != nil {
+			os.Remove(infoPath)
+			return "", "", err
+		}
+		return name, infoPath, nil
+	}
+}
+
+// trashInfoPath devuelve el .trashinfo asociado a un archivo en files/.
+func trashInfoPath(location string) string {
+	trashDir := filepath.Dir(filepath.Dir(location))
+	return filepath.Join(trashDir, "info", filepath.Base(location)+".trashinfo")
+}
+
+// ensureTrashDirs crea files/ e info/ con permisos 0700 y rechaza symlinks.
+func ensureTrashDirs(dir string) error {
+	for _, sub := range []string{"files", "info"} {
+		p := filepath.Join(dir, sub)
+		if err := os.MkdirAll(p, 0700); err != nil {
+			return err
+		}
+		info, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s no es un directorio", p)
+		}
+	}
+	return nil
+}
+
+// mountPoint sube por el árbol mientras el dispositivo no cambie.
+func mountPoint(path string, dev uint64) (string, error) {
+	dir := filepath.Dir(path)
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir, nil
+		}
+		pdev, err := deviceOf(parent)
+		if err != nil {
+			return "", err
+		}
+		if pdev != dev {
+			return dir, nil
+		}
+		dir = parent
+	}
+}
+
+// deviceOf devuelve el st_dev de una ruta (sin seguir symlinks).
+func deviceOf(path string) (uint64, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("sin información de dispositivo para %s", path)
+	}
+	return uint64(stat.Dev), nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestTrash crea una papelera XDG en un $XDG_DATA_HOME temporal.
+func newTestTrash(t *testing.T) *xdgTrash {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	trash, err := newXDGTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return trash
+}
+
+func TestXDGTrashMove(t *testing.T) {
+	trash := newTestTrash(t)
+	dir := t.TempDir()
+	first := writeFile(t, dir, "mis fotos/playa 1.jpg", "uno")
+	second := writeFile(t, dir, "otra/playa 1.jpg", "dos")
+
+	before := time.Now().Truncate(time.Second)
+	loc1, err := trash.Move(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc2, err := trash.Move(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mismo nombre: el segundo recibe un sufijo en files/ e info/
+	files := filepath.Join(trash.Home(), "files")
+	if loc1 != filepath.Join(files, "playa 1.jpg") || loc2 != filepath.Join(files, "playa 1.jpg.2") {
+		t.Errorf("ubicaciones = %s, %s", loc1, loc2)
+	}
+	if readFile(t, loc1) != "uno" || readFile(t, loc2) != "dos" {
+		t.Error("contenido movido distinto")
+	}
+
+	info := readFile(t, trashInfoPath(loc1))
+	lines := strings.Split(strings.TrimSuffix(info, "\n"), "\n")
+	if len(lines) != 3 || lines[0] != "[Trash Info]" {
+		t.Fatalf(".trashinfo = %q", info)
+	}
+	// Ruta absoluta con percent-encoding (los espacios no van tal cual)
+	wantPath := "Path=" + strings.ReplaceAll(first, " ", "%20")
+	if lines[1] != wantPath {
+		t.Errorf("%s, quería %s", lines[1], wantPath)
+	}
+	date, err := time.ParseInLocation("2006-01-02T15:04:05", strings.TrimPrefix(lines[2], "DeletionDate="), time.Local)
+	if err != nil || date.Before(before) || date.After(time.Now()) {
+		t.Errorf("%s: %v", lines[2], err)
+	}
+}
+
+// restore devuelve el archivo y borra su .trashinfo (el gestor de archivos ya no lo lista).
+func TestXDGTrashRestore(t *testing.T) {
+	trash := newTestTrash(t)
+	p := writeFile(t, t.TempDir(), "a.txt", "contenido")
+	loc, err := trash.Move(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []JournalEntry{{Action: ActionXDGTrash, Original: p, Location: loc}}
+	if restored, _, failed := restoreEntries(entries, false); restored != 1 || failed != 0 {
+		t.Fatalf("restaurados = %d, errores = %d", restored, failed)
+	}
+	if readFile(t, p) != "contenido" {
+		t.Error("contenido restaurado distinto")
+	}
+	if _, err := os.Lstat(trashInfoPath(loc)); err == nil {
+		t.Error("el .trashinfo sigue en la papelera")
+	}
+}
@@ -2,4 +2,12 @@ module github.com/soyunomas/dupedetector
 
 go 1.25.3
 
-require github.com/cespare/xxhash/v2 v2.3.0
+require (
+	github.com/bmatcuk/doublestar/v4 v4.10.2
+	github.com/cespare/xxhash/v2 v2.3.0
+	github.com/zeebo/xxh3 v1.1.0
+	golang.org/x/crypto v0.43.0
+	golang.org/x/sys v0.37.0
+)
+
+require github.com/klauspost/cpuid/v2 v2.2.10 // indirect
@@ -0,0 +1,486 @@
+// Package archive expone los miembros de archivos .zip y .tar (también .tar.gz
+// y .tar.bz2) como archivos virtuales de solo lectura, con rutas del tipo
+// "backup.zip!/src/main.go". No se extrae nada a disco: cada miembro se lee
+// descomprimiendo en streaming.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soyunomas/dupedetector/internal/vfs"
+)
+
+// Sep separa la ruta del archivo comprimido de la del miembro.
+const Sep = "!/"
+
+// format es el tipo de contenedor, deducido de la extensión.
+type format int
+
+const (
+	formatNone format = iota
+	formatZip
+	formatTar
+	formatTarGz
+	formatTarBz2
+)
+
+// suffixes asocia cada extensión reconocida con su formato.
+var suffixes = []struct {
+	suffix string
+	format format
+}{
+	{".zip", formatZip},
+	{".tar", formatTar},
+	{".tar.gz", formatTarGz}, {".tgz", formatTarGz},
+	{".tar.bz2", formatTarBz2}, {".tbz2", formatTarBz2}, {".tbz", formatTarBz2},
+}
+
+func formatOf(name string) format {
+	lower := strings.ToLower(name)
+	for _, s := range suffixes {
+		if strings.HasSuffix(lower, s.suffix) {
+			return s.format
+		}
+	}
+	return formatNone
+}
+
+// IsArchive indica si name tiene una extensión de archivo comprimido reconocida.
+func IsArchive(name string) bool {
+	return formatOf(name) != formatNone
+}
+
+// Sequential indica si los miembros de name solo se pueden leer en orden (tar
+// comprimido): abrir uno suelto obliga a descomprimir todo lo anterior, así que
+// conviene leer los que interesen de una pasada con FS.Each.
+func Sequential(name string) bool {
+	fm := formatOf(name)
+	return fm == formatTarGz || fm == formatTarBz2
+}
+
+// Join construye la ruta virtual de un miembro: "backup.zip!/src/main.go".
+func Join(archive, member string) string {
+	return archive + Sep + member
+}
+
+// MemberOf devuelve la ruta interna de p si p es la ruta virtual de un miembro
+// de archive ("a.zip!/src/x" -> "src/x").
+func MemberOf(archive, p string) (string, bool) {
+	return strings.CutPrefix(p, archive+Sep)
+}
+
+// Split separa una ruta virtual en archivo comprimido y miembro.
+// Solo cuenta un Sep precedido de una extensión reconocida: "fotos!/a.jpg" es una ruta normal.
+// Es un análisis de texto ("copia.zip!/" también puede ser una carpeta real): sirve
+// para rutas de fuera, como las de un reporte JSON. Al leer, el FileInfo.Archive del
+// escaneo dice qué es un miembro (ver FS.In).
+func Split(p string) (archive, member string, ok bool) {
+	for i := 0; ; {
+		j := strings.Index(p[i:], Sep)
+		if j < 0 {
+			return "", "", false
+		}
+		i += j
+		if IsArchive(p[:i]) {
+			return p[:i], p[i+len(Sep):], true
+		}
+		i += len(Sep)
+	}
+}
+
+// Member es un archivo regular dentro de un archivo comprimido.
+type Member struct {
+	Name    string // Ruta dentro del archivo comprimido ("src/main.go")
+	Size    int64  // Tamaño descomprimido
+	ModTime time.Time
+}
+
+// Members lista los archivos regulares de name (directorios, symlinks y
+// demás entradas se ignoran). Los tar comprimidos se leen enteros.
+// Un nombre repetido (tar con entradas añadidas) se lista una sola vez.
+func Members(fsys fs.FS, name string) ([]Member, error) {
+	r, err := openReader(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var out []Member
+	seen := make(map[string]struct{})
+	for {
+		e, err := r.next()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return out, &fs.PathError{Op: "read", Path: name, Err: err}
+		}
+		if _, dup := seen[e.name]; dup {
+			continue
+		}
+		seen[e.name] = struct{}{}
+		out = append(out, Member{Name: e.name, Size: e.info.Size(), ModTime: e.info.ModTime()})
+	}
+}
+
+// entry es un miembro regular. open solo es válido hasta la siguiente llamada a next.
+type entry struct {
+	name string
+	info fs.FileInfo
+	open func() (io.ReadCloser, error)
+}
+
+// reader recorre los miembros regulares de un archivo comprimido abierto.
+type reader struct {
+	f    fs.File
+	next func() (entry, error) // io.EOF al terminar
+}
+
+func openReader(fsys fs.FS, name string) (*reader, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	r := &reader{f: f}
+
+	fm := formatOf(name)
+	if fm == formatZip {
+		zr, err := openZip(f)
+		if err != nil {
+			f.Close()
+			return nil, &fs.PathError{Op: "zip", Path: name, Err: err}
+		}
+		i := 0
+		r.next = func() (entry, error) {
+			for ; i < len(zr.File); i++ {
+				if zf := zr.File[i]; zf.Mode().IsRegular() {
+					i++
+					return entry{zf.Name, zf.FileInfo(), zf.Open}, nil
+				}
+			}
+			return entry{}, io.EOF
+		}
+		return r, nil
+	}
+
+	dr, err := decompress(f, fm)
+	if err != nil {
+		f.Close()
+		return nil, &fs.PathError{Op: "tar", Path: name, Err: err}
+	}
+	tr := tar.NewReader(dr)
+	open := func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }
+	r.next = func() (entry, error) {
+		for {
+			hdr, err := tr.Next()
+			if err != nil {
+				return entry{}, err
+			}
+			if info := hdr.FileInfo(); info.Mode().IsRegular() {
+				return entry{hdr.Name, info, open}, nil
+			}
+		}
+	}
+	return r, nil
+}
+
+func (r *reader) Close() error {
+	return r.f.Close()
+}
+
+// openZip lee el directorio central. Necesita acceso aleatorio: en disco es
+// directo; si el FS no ofrece ReadAt, el zip se carga en memoria.
+func openZip(f fs.File) (*zip.Reader, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if ra, ok := f.(io.ReaderAt); ok {
+		return zip.NewReader(ra, info.Size())
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return zip.NewReader(bytes.NewReader(data), int64(len(data)))
+}
+
+func decompress(r io.Reader, fm format) (io.Reader, error) {
+	switch fm {
+	case formatTarGz:
+		return gzip.NewReader(r)
+	case formatTarBz2:
+		return bzip2.NewReader(r), nil
+	default:
+		return r, nil
+	}
+}
+
+// FS lee los miembros de los archivos comprimidos de otro FS. Una ruta con Sep no
+// se interpreta nunca por sí sola (puede ser una carpeta real llamada "x.zip!"):
+// quien abre un miembro dice de qué comprimido es, con In u OpenMember.
+type FS struct {
+	base fs.FS
+
+	mu    sync.Mutex
+	spans map[string]map[string]span // tar sin comprimir: dónde empieza cada miembro
+}
+
+// span es la posición de los datos de un miembro dentro de un tar sin comprimir.
+type span struct {
+	offset int64
+	info   fs.FileInfo
+}
+
+// NewFS devuelve un lector de los comprimidos de base.
+func NewFS(base fs.FS) *FS {
+	return &FS{base: base, spans: make(map[string]map[string]span)}
+}
+
+// In devuelve un FS que solo abre las rutas virtuales de los miembros de archive.
+func (a *FS) In(archive string) fs.FS {
+	return members{a, archive}
+}
+
+// OpenMember abre un miembro de solo lectura. En un zip o un tar sin comprimir se
+// accede directo a sus datos; en un tar comprimido se descomprime todo lo que
+// haya antes del miembro (para leer varios, mejor Each).
+func (a *FS) OpenMember(archive, member string) (fs.File, error) {
+	name := Join(archive, member)
+	if formatOf(archive) == formatTar {
+		if f, err := a.openSpan(archive, member); f != nil || err != nil {
+			return f, err
+		}
+	}
+
+	r, err := openReader(a.base, archive)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		e, err := r.next()
+		if err == io.EOF {
+			err = fs.ErrNotExist
+		}
+		if err != nil {
+			r.Close()
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		if e.name != member {
+			continue
+		}
+		rc, err := e.open()
+		if err != nil {
+			r.Close()
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &memberFile{info: e.info, rc: rc, archive: r}, nil
+	}
+}
+
+// openSpan abre un miembro de un tar sin comprimir con acceso directo a sus datos.
+// Las posiciones se calculan una vez por tar. Devuelve (nil, nil) si el tar no
+// admite acceso aleatorio o el miembro no tiene un tramo contiguo (sparse):
+// entonces se lee en streaming.
+func (a *FS) openSpan(archive, member string) (fs.File, error) {
+	spans, err := a.tarSpans(archive)
+	if err != nil || spans == nil {
+		return nil, err
+	}
+	sp, ok := spans[member]
+	if !ok {
+		return nil, nil
+	}
+
+	f, err := a.base.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	ra, ok := f.(io.ReaderAt)
+	if !ok {
+		f.Close()
+		return nil, nil
+	}
+	rc := io.NopCloser(io.NewSectionReader(ra, sp.offset, sp.info.Size()))
+	return &memberFile{info: sp.info, rc: rc, archive: f}, nil
+}
+
+// tarSpans indexa un tar sin comprimir leyendo solo sus cabeceras (los datos se
+// saltan con Seek). nil si el FS no ofrece acceso aleatorio.
+func (a *FS) tarSpans(archive string) (map[string]span, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if spans, ok := a.spans[archive]; ok {
+		return spans, nil
+	}
+
+	f, err := a.base.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sk, ok := f.(io.ReadSeeker)
+	if _, ra := f.(io.ReaderAt); !ok || !ra {
+		a.spans[archive] = nil
+		return nil, nil
+	}
+
+	spans := make(map[string]span)
+	seen := make(map[string]struct{})
+	pr := &posReader{r: sk}
+	tr := tar.NewReader(pr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, &fs.PathError{Op: "tar", Path: archive, Err: err}
+		}
+		info := hdr.FileInfo()
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		// Como Members y el streaming: cuenta la primera aparición de cada nombre
+		if _, dup := seen[hdr.Name]; dup {
+			continue
+		}
+		seen[hdr.Name] = struct{}{}
+		if !sparse(hdr) {
+			spans[hdr.Name] = span{offset: pr.pos, info: info}
+		}
+	}
+	a.spans[archive] = spans
+	return spans, nil
+}
+
+// sparse indica si los datos del miembro no son un tramo contiguo del tar.
+func sparse(hdr *tar.Header) bool {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for k := range hdr.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// posReader lleva la cuenta de la posición en el tar. Tras tar.Reader.Next,
+// pos es el inicio de los datos del miembro.
+type posReader struct {
+	r   io.ReadSeeker
+	pos int64
+}
+
+func (p *posReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.pos += int64(n)
+	return n, err
+}
+
+func (p *posReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := p.r.Seek(offset, whence)
+	if err == nil {
+		p.pos = pos
+	}
+	return pos, err
+}
+
+// Each lee archive de una sola pasada y llama a fn con cada miembro de want
+// (su ruta virtual y el contenido, válido solo durante fn). Es la forma de leer
+// varios miembros de un tar comprimido sin descomprimirlo una vez por miembro.
+// Devuelve el error de apertura o lectura del comprimido (o de ctx, o de fn): los
+// miembros de want que no se visitaron no existen o quedaron detrás del error.
+func (a *FS) Each(ctx context.Context, archive string, want map[string]bool, fn func(path string, f fs.File) error) error {
+	r, err := openReader(a.base, archive)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	pending := len(want)
+	seen := make(map[string]struct{})
+	for pending > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		e, err := r.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return &fs.PathError{Op: "read", Path: archive, Err: err}
+		}
+		if _, dup := seen[e.name]; dup || !want[e.name] {
+			continue
+		}
+		seen[e.name] = struct{}{}
+		pending--
+
+		rc, err := e.open()
+		if err != nil {
+			return &fs.PathError{Op: "open", Path: Join(archive, e.name), Err: err}
+		}
+		err = fn(Join(archive, e.name), &memberFile{info: e.info, rc: io.NopCloser(rc), archive: nopCloser{}})
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// members es el FS de los miembros de un comprimido (ver FS.In).
+type members struct {
+	a       *FS
+	archive string
+}
+
+func (m members) Open(name string) (fs.File, error) {
+	member, ok := MemberOf(m.archive, name)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return m.a.OpenMember(m.archive, member)
+}
+
+// Sys delega la identidad física en el FS base. Los miembros no tienen inodo propio.
+func (m members) Sys(info fs.FileInfo) vfs.SysInfo {
+	return vfs.Sys(m.a.base, info)
+}
+
+// memberFile es un miembro abierto (solo lectura secuencial).
+type memberFile struct {
+	info    fs.FileInfo
+	rc      io.ReadCloser
+	archive io.Closer // El comprimido: se cierra junto con el miembro
+}
+
+func (m *memberFile) Stat() (fs.FileInfo, error) { return m.info, nil }
+
+func (m *memberFile) Read(p []byte) (int, error) { return m.rc.Read(p) }
+
+func (m *memberFile) Close() error {
+	err := m.rc.Close()
+	if cerr := m.archive.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// nopCloser: en Each, miembro y comprimido los cierra Each, no quien lee.
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
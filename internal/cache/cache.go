@@ -0,0 +1,218 @@
+package cache
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/soyunomas/dupedetector/internal/entities"
+)
+
+// formatVersion se incrementa cuando cambia la estructura del archivo.
+const formatVersion = 1
+
+// MaxAge: entradas no vistas en este tiempo se purgan al guardar.
+const MaxAge = 90 * 24 * time.Hour
+
+// fileID identifica un archivo físico (independiente de su ruta).
+type fileID struct {
+	DeviceID uint64
+	Inode    uint64
+}
+
+// record guarda los digests junto a los metadatos que los validan.
+// Si cambia el tamaño, mtime o ctime, el registro deja de ser válido.
+type record struct {
+	Size       int64
+	ModTime    int64 // UnixNano
+	ChangeTime int64 // UnixNano
+	PreHash    entities.Digest
+	FullHash   entities.Digest
+	LastSeen   int64 // Unix
+}
+
+// diskFormat es lo que se serializa (gob) en disco.
+type diskFormat struct {
+	Version   int
+	Algorithm string
+	Records   map[fileID]record
+}
+
+// Cache es una caché persistente de hashes, una por algoritmo.
+// Es segura para uso concurrente desde los workers.
+type Cache struct {
+	mu      sync.Mutex
+	path    string
+	algo    string
+	records map[fileID]record
+	now     int64
+	dirty   bool
+}
+
+// DefaultPath devuelve la ruta del archivo de caché para un algoritmo.
+// Respeta $XDG_CACHE_HOME (vía os.UserCacheDir).
+func DefaultPath(algo string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "dupedetector", "hashes-"+algo+".gob"), nil
+}
+
+// Open carga la caché del algoritmo indicado. Si no existe o está corrupta
+// se empieza con una vacía: la caché nunca debe impedir un escaneo.
+func Open(algo string) (*Cache, error) {
+	path, err := DefaultPath(algo)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cache{
+		path:    path,
+		algo:    algo,
+		records: make(map[fileID]record),
+		now:     time.Now().Unix(),
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var data diskFormat
+	if err := gob.NewDecoder(f).Decode(&data); err != nil {
+		return c, nil
+	}
+	if data.Version == formatVersion && data.Algorithm == algo && data.Records != nil {
+		c.records = data.Records
+	}
+	return c, nil
+}
+
+// Path devuelve la ubicación del archivo de caché.
+func (c *Cache) Path() string {
+	return c.path
+}
+
+// Lookup devuelve los digests guardados para f si sus metadatos no cambiaron.
+// Un digest vacío significa que esa fase aún no se calculó.
+func (c *Cache) Lookup(f *entities.FileInfo) (preHash, fullHash entities.Digest, ok bool) {
+	if f.Inode == 0 {
+		return "", "", false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := fileID{f.DeviceID, f.Inode}
+	rec, exists := c.records[id]
+	if !exists || !rec.matches(f) {
+		return "", "", false
+	}
+
+	if rec.LastSeen != c.now {
+		rec.LastSeen = c.now
+		c.records[id] = rec
+		c.dirty = true
+	}
+	return rec.PreHash, rec.FullHash, true
+}
+
+// StorePreHash guarda el hash parcial de f.
+func (c *Cache) StorePreHash(f *entities.FileInfo, d entities.Digest) {
+	c.store(f, func(rec *record) { rec.PreHash = d })
+}
+
+// StoreFullHash guarda el hash completo de f.
+func (c *Cache) StoreFullHash(f *entities.FileInfo, d entities.Digest) {
+	c.store(f, func(rec *record) { rec.FullHash = d })
+}
+
+func (c *Cache) store(f *entities.FileInfo, set func(*record)) {
+	if f.Inode == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := fileID{f.DeviceID, f.Inode}
+	rec, exists := c.records[id]
+	if !exists || !rec.matches(f) {
+		// Invalidación: el archivo cambió, descartamos los digests viejos
+		rec = record{
+			Size:       f.Size,
+			ModTime:    nanos(f.ModTime),
+			ChangeTime: nanos(f.ChangeTime),
+		}
+	}
+	set(&rec)
+	rec.LastSeen = c.now
+	c.records[id] = rec
+	c.dirty = true
+}
+
+// Save escribe la caché en disco de forma atómica (tmp + rename),
+// purgando las entradas que no se han visto en MaxAge.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	cutoff := c.now - int64(MaxAge/time.Second)
+	for id, rec := range c.records {
+		if rec.LastSeen < cutoff {
+			delete(c.records, id)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".hashes-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	data := diskFormat{Version: formatVersion, Algorithm: c.algo, Records: c.records}
+	if err := gob.NewEncoder(tmp).Encode(&data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("serializando caché: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return err
+	}
+
+	c.dirty = false
+	return nil
+}
+
+// matches indica si el registro sigue siendo válido para el archivo.
+func (rec record) matches(f *entities.FileInfo) bool {
+	return rec.Size == f.Size &&
+		rec.ModTime == nanos(f.ModTime) &&
+		rec.ChangeTime == nanos(f.ChangeTime)
+}
+
+// nanos evita el valor indefinido de UnixNano para time.Time{}.
+func nanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
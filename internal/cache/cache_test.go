@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/soyunomas/dupedetector/internal/entities"
+)
+
+// open crea una caché vacía en un $XDG_CACHE_HOME temporal.
+func open(t *testing.T) *Cache {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	c, err := Open("xxhash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestLookup(t *testing.T) {
+	mtime := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	ctime := mtime.Add(time.Minute)
+	stored := entities.FileInfo{Path: "a.jpg", Size: 100, ModTime: mtime, ChangeTime: ctime, DeviceID: 1, Inode: 42}
+
+	tests := []struct {
+		name   string
+		change func(f *entities.FileInfo)
+		hit    bool
+	}{
+		{"sin cambios", func(f *entities.FileInfo) {}, true},
+		{"otra ruta, mismo inodo", func(f *entities.FileInfo) { f.Path = "copia/a.jpg" }, true},
+		{"tamaño", func(f *entities.FileInfo) { f.Size++ }, false},
+		{"mtime", func(f *entities.FileInfo) { f.ModTime = f.ModTime.Add(time.Nanosecond) }, false},
+		{"ctime", func(f *entities.FileInfo) { f.ChangeTime = f.ChangeTime.Add(time.Second) }, false},
+		{"otro inodo", func(f *entities.FileInfo) { f.Inode++ }, false},
+		{"sin inodo", func(f *entities.FileInfo) { f.Inode = 0 }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := open(t)
+			f := stored
+			c.StorePreHash(&f, "pre")
+			c.StoreFullHash(&f, "full")
+
+			tt.change(&f)
+			pre, full, ok := c.Lookup(&f)
+			if ok != tt.hit {
+				t.Fatalf("Lookup ok = %v, quería %v", ok, tt.hit)
+			}
+			if ok && (pre != "pre" || full != "full") {
+				t.Errorf("Lookup = %q, %q", pre, full)
+			}
+		})
+	}
+}
+
+// Un archivo que cambió invalida los digests viejos: no se mezclan con los nuevos.
+func TestStoreInvalidates(t *testing.T) {
+	c := open(t)
+	f := entities.FileInfo{Size: 100, ModTime: time.Unix(1000, 0), DeviceID: 1, Inode: 42}
+	c.StorePreHash(&f, "pre")
+	c.StoreFullHash(&f, "full")
+
+	f.ModTime = f.ModTime.Add(time.Second)
+	c.StorePreHash(&f, "pre2")
+	pre, full, ok := c.Lookup(&f)
+	if !ok || pre != "pre2" || full != "" {
+		t.Errorf("Lookup = %q, %q, %v; quería pre2, vacío, true", pre, full, ok)
+	}
+}
+
+func TestSaveReopen(t *testing.T) {
+	c := open(t)
+	f := entities.FileInfo{Size: 100, ModTime: time.Unix(1000, 0), ChangeTime: time.Unix(2000, 0), DeviceID: 1, Inode: 42}
+	c.StoreFullHash(&f, "full")
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := Open("xxhash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, full, ok := again.Lookup(&f); !ok || full != "full" {
+		t.Errorf("tras reabrir: %q, %v", full, ok)
+	}
+	// Cada algoritmo tiene su propio archivo
+	other, err := Open("sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := other.Lookup(&f); ok {
+		t.Error("la caché de sha256 devolvió un digest de xxhash")
+	}
+}
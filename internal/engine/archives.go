@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"context"
+	"io/fs"
+
+	"github.com/soyunomas/dupedetector/internal/archive"
+	"github.com/soyunomas/dupedetector/internal/entities"
+)
+
+// fsOf devuelve el FS del que se lee f: el de Options.FS o, si f es un miembro
+// (FileInfo.Archive), el de su comprimido. La ruta por sí sola no basta: una
+// carpeta real puede llamarse "copia.zip!".
+func (r *Runner) fsOf(f *entities.FileInfo) fs.FS {
+	if f.Archive != "" && r.archives != nil {
+		return r.archives.In(f.Archive)
+	}
+	return r.opts.FS
+}
+
+// splitSequential separa los miembros de tar comprimidos, agrupados por
+// comprimido: hashearlos uno a uno obligaría a descomprimir desde el principio
+// para cada miembro. Cada lote se lee después de una pasada con eachMember.
+func (r *Runner) splitSequential(files []*entities.FileInfo) (rest []*entities.FileInfo, batches [][]*entities.FileInfo) {
+	if r.archives == nil {
+		return files, nil
+	}
+	byArchive := make(map[string]int)
+	for _, f := range files {
+		if f.Archive == "" || !archive.Sequential(f.Archive) {
+			rest = append(rest, f)
+			continue
+		}
+		i, ok := byArchive[f.Archive]
+		if !ok {
+			i = len(batches)
+			byArchive[f.Archive] = i
+			batches = append(batches, nil)
+		}
+		batches[i] = append(batches[i], f)
+	}
+	return rest, batches
+}
+
+// eachMember lee de una pasada los miembros de batch (todos del mismo comprimido)
+// y llama a fn con cada uno, abierto en un FS de un solo archivo para poder usar
+// el Hasher tal cual. Un miembro que no se pudo leer llega con err.
+func (r *Runner) eachMember(ctx context.Context, batch []*entities.FileInfo, fn func(f *entities.FileInfo, fsys fs.FS, err error)) {
+	pending := make(map[string]*entities.FileInfo, len(batch))
+	want := make(map[string]bool, len(batch))
+	for _, f := range batch {
+		if member, ok := archive.MemberOf(f.Archive, f.Path); ok {
+			pending[f.Path] = f
+			want[member] = true
+		}
+	}
+
+	err := r.archives.Each(ctx, batch[0].Archive, want, func(path string, file fs.File) error {
+		if f, ok := pending[path]; ok {
+			delete(pending, path)
+			fn(f, memberFS{path, file}, nil)
+		}
+		return nil
+	})
+	if err == nil {
+		err = fs.ErrNotExist
+	}
+	for _, f := range batch {
+		if _, ok := pending[f.Path]; ok {
+			fn(f, nil, &fs.PathError{Op: "open", Path: f.Path, Err: err})
+		}
+	}
+}
+
+// memberFS expone un único miembro ya abierto (por archive.FS.Each) como fs.FS.
+type memberFS struct {
+	path string
+	file fs.File
+}
+
+func (m memberFS) Open(name string) (fs.File, error) {
+	if name != m.path {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return m.file, nil
+}
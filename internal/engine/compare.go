@@ -0,0 +1,244 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/soyunomas/dupedetector/internal/entities"
+	"github.com/soyunomas/dupedetector/internal/scanner"
+	"github.com/soyunomas/dupedetector/internal/vfs"
+)
+
+// CompareStats es el resultado de Compare.
+type CompareStats struct {
+	TargetFiles int64                 // Archivos escaneados en target
+	SourceFiles int64                 // Archivos escaneados en source (fuera de target)
+	Found       []*entities.FileGroup // Copias en source (Reference) y archivos de target (el resto), en cada grupo
+	Unique      []*entities.FileInfo  // Archivos de target sin copia en source (ordenados por ruta)
+	Skipped     []string              // Entradas de target que no se compararon (filtros, exclusiones, symlinks...)
+	Duration    time.Duration
+	Errors      []entities.PathError // Un archivo de target ilegible no está ni en Found ni en Unique
+	Interrupted bool
+}
+
+// Compare responde "¿qué hay en target que no esté ya en source?" sin agrupar
+// todo contra todo: el mismo pipeline que Run (tamaño -> pre-hash -> hash
+// completo -> verificación opcional), pero solo se leen los archivos de source
+// cuyo tamaño coincide con alguno de target, y solo se agrupan contenidos que
+// aparezcan en ambos lados.
+//
+// Los archivos de source se tratan como referencias: van delante en cada grupo y
+// nunca son víctimas. Lo que esté dentro de target no cuenta como copia aunque
+// también cuelgue de source (borrar target se lo llevaría). Los miembros de
+// comprimidos (Options.Archives) solo cuentan en source; References no se usa.
+func (r *Runner) Compare(ctx context.Context, source, target []string) (*CompareStats, error) {
+	start := time.Now()
+	r.errs = nil
+	stats := &CompareStats{}
+
+	// --- PASO 1: SCANNER (target y después source) ---
+	r.emit(Event{Kind: EventPhaseStarted, Phase: entities.PhaseScan})
+	var discovered int64
+
+	cfg := r.scanConfig(&discovered)
+	cfg.Archives, cfg.References = false, nil
+	cfg.RecordSkipped = true
+	targetBySize, skipped, err := r.scanSide(ctx, cfg, target)
+	if err != nil {
+		return nil, err
+	}
+	stats.Skipped = skipped
+
+	inTarget := r.withinAny(target)
+	var sourceBySize map[int64]*entities.FileGroup
+	if ctx.Err() == nil {
+		cfg = r.scanConfig(&discovered)
+		cfg.References = nil
+		if sourceBySize, _, err = r.scanSide(ctx, cfg, source); err != nil {
+			return nil, err
+		}
+	}
+
+	// Solo interesan los tamaños presentes en target
+	var candidates []*entities.FileInfo
+	for size, group := range targetBySize {
+		stats.TargetFiles += group.Count
+		var copies []*entities.FileInfo
+		if g, ok := sourceBySize[size]; ok {
+			for _, f := range g.Files {
+				if !inTarget(f.Path) {
+					copies = append(copies, f)
+				}
+			}
+		}
+		if len(copies) == 0 {
+			stats.Unique = append(stats.Unique, group.Files...)
+			continue
+		}
+		candidates = append(candidates, group.Files...)
+		candidates = append(candidates, copies...)
+	}
+	for _, group := range sourceBySize {
+		for _, f := range group.Files {
+			if !inTarget(f.Path) {
+				f.Reference = true
+				stats.SourceFiles++
+			}
+		}
+	}
+	r.finishPhase(ctx, Event{Phase: entities.PhaseScan, Files: stats.TargetFiles + stats.SourceFiles, Candidates: int64(len(candidates))})
+	if err := ctx.Err(); err != nil {
+		return r.compareStats(ctx, start, stats), err
+	}
+
+	// --- PASO 2: PRE-HASHING ---
+	preHashGroups, preHits := r.processPreHash(ctx, candidates)
+	var finalCandidates []*entities.FileInfo
+	for _, files := range preHashGroups {
+		if bothSides(files) {
+			finalCandidates = append(finalCandidates, files...)
+		} else {
+			stats.Unique = append(stats.Unique, targetOnly(files)...)
+		}
+	}
+	r.finishPhase(ctx, Event{Phase: entities.PhasePreHash, Files: int64(len(candidates)), Candidates: int64(len(finalCandidates)), CacheHits: preHits})
+	if err := ctx.Err(); err != nil {
+		return r.compareStats(ctx, start, stats), err
+	}
+
+	// --- PASO 3: FULL HASHING ---
+	finalGroups, fullHits := r.processFullHash(ctx, finalCandidates)
+	var found []*entities.FileGroup
+	var inGroups int64
+	for _, group := range finalGroups {
+		if bothSides(group.Files) {
+			found = append(found, group)
+			inGroups += group.Count
+		} else {
+			stats.Unique = append(stats.Unique, targetOnly(group.Files)...)
+		}
+	}
+	sortGroups(found, r.opts.Strategy)
+	r.finishPhase(ctx, Event{Phase: entities.PhaseHash, Files: int64(len(finalCandidates)), Candidates: inGroups, CacheHits: fullHits})
+
+	// --- PASO 4: VERIFICACIÓN BYTE A BYTE (Opcional) ---
+	// Un archivo de target que no queda junto a una copia de source es único,
+	// salvo que no se haya podido leer (entonces solo figura en Errors).
+	if r.opts.Verify && ctx.Err() == nil {
+		before := found
+		total := int64(len(found))
+		var splits int
+		found, splits = r.verifyGroups(ctx, found)
+		r.finishPhase(ctx, Event{Phase: entities.PhaseVerify, Files: total, Candidates: int64(len(found)), Splits: splits})
+
+		kept := make(map[string]bool)
+		var verified []*entities.FileGroup
+		for _, g := range found {
+			// Los miembros de comprimidos van al final: la copia de source puede no ser Files[0]
+			if !bothSides(g.Files) {
+				continue
+			}
+			verified = append(verified, g)
+			for _, f := range g.Files {
+				kept[f.Path] = true
+			}
+		}
+		if ctx.Err() == nil {
+			unreadable := make(map[string]bool)
+			for _, e := range r.errs {
+				unreadable[e.Path] = true
+			}
+			for _, g := range before {
+				for _, f := range targetOnly(g.Files) {
+					if !kept[f.Path] && !unreadable[f.Path] {
+						stats.Unique = append(stats.Unique, f)
+					}
+				}
+			}
+		}
+		found = verified
+	}
+
+	stats.Found = found
+	return r.compareStats(ctx, start, stats), ctx.Err()
+}
+
+// scanSide recorre un lado de la comparación con su propio scanner.
+// Devuelve también las entradas que el scanner saltó a propósito.
+func (r *Runner) scanSide(ctx context.Context, cfg scanner.Config, roots []string) (map[int64]*entities.FileGroup, []string, error) {
+	sc := scanner.New(cfg)
+	filesBySize, err := sc.Scan(ctx, roots...)
+	if err != nil && ctx.Err() == nil {
+		return nil, nil, fmt.Errorf("fallo en scanner: %w", err)
+	}
+	for _, e := range sc.Errors() {
+		r.addError(e)
+	}
+	return filesBySize, sc.Skipped(), nil
+}
+
+// compareStats cierra el resultado (o parcial, si se canceló).
+func (r *Runner) compareStats(ctx context.Context, start time.Time, stats *CompareStats) *CompareStats {
+	sort.Slice(stats.Unique, func(i, j int) bool { return stats.Unique[i].Path < stats.Unique[j].Path })
+	sort.Strings(stats.Skipped)
+	stats.Duration = time.Since(start)
+	stats.Errors = r.errs
+	stats.Interrupted = ctx.Err() != nil
+	return stats
+}
+
+// withinAny devuelve si una ruta escaneada está bajo alguna de las raíces.
+// En disco se compara en absoluto ("." y "/home/ana" pueden ser lo mismo).
+func (r *Runner) withinAny(roots []string) func(string) bool {
+	clean := func(p string) string {
+		if r.opts.FS != vfs.OS {
+			return path.Clean(p)
+		}
+		if abs, err := filepath.Abs(p); err == nil {
+			return abs
+		}
+		return filepath.Clean(p)
+	}
+	prefixes := make([]string, 0, len(roots))
+	for _, root := range roots {
+		prefixes = append(prefixes, clean(root))
+	}
+	return func(p string) bool {
+		p = clean(p)
+		for _, root := range prefixes {
+			if p == root || root == "." || root == "/" || strings.HasPrefix(p, root+"/") {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// bothSides indica si hay archivos de source (Reference) y de target.
+func bothSides(files []*entities.FileInfo) bool {
+	var src, dst bool
+	for _, f := range files {
+		if f.Reference {
+			src = true
+		} else {
+			dst = true
+		}
+	}
+	return src && dst
+}
+
+// targetOnly filtra los archivos de target.
+func targetOnly(files []*entities.FileInfo) []*entities.FileInfo {
+	var out []*entities.FileInfo
+	for _, f := range files {
+		if !f.Reference {
+			out = append(out, f)
+		}
+	}
+	return out
+}
@@ -1,15 +1,22 @@
 package engine
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io/fs"
+	"regexp"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/soyunomas/dupedetector/internal/archive"
+	"github.com/soyunomas/dupedetector/internal/cache"
 	"github.com/soyunomas/dupedetector/internal/entities"
 	"github.com/soyunomas/dupedetector/internal/hasher"
 	"github.com/soyunomas/dupedetector/internal/scanner"
+	"github.com/soyunomas/dupedetector/internal/vfs"
 )
 
 // Definimos las estrategias de conservación disponibles
@@ -22,101 +29,240 @@ const (
 	KeepNewest
 )
 
+// progressTick: cada cuánto se publica el avance en bytes mientras se hashean archivos grandes.
+const progressTick = 250 * time.Millisecond
+
 type Options struct {
-	MinSize  int64
-	Excludes []string
-	Strategy KeepStrategy
+	MinSize        int64
+	MaxSize        int64     // 0 = sin límite
+	NewerThan      time.Time // Filtros de mtime (cero = sin límite)
+	OlderThan      time.Time
+	Extensions     []string // Extensiones permitidas (vacío = todas)
+	ExcludeExts    []string
+	Excludes       []string         // Nombres o globs (ver scanner.Config)
+	Includes       []string         // Globs de archivos a considerar (vacío = todos)
+	ExcludeRegex   []*regexp.Regexp // Regex sobre la ruta relativa a cada raíz
+	IncludeRegex   []*regexp.Regexp
+	IgnoreFiles    []string // Archivos estilo .gitignore a respetar en cada directorio
+	FollowSymlinks bool     // Seguir symlinks (con detección de bucles)
+	OneFileSystem  bool     // No cruzar puntos de montaje
+	Archives       bool     // Buscar también dentro de .zip/.tar(.gz/.bz2): miembros virtuales, nunca víctimas
+	References     []string // Carpetas de solo lectura: sus archivos nunca son víctimas
+	Strategy       KeepStrategy
+	Verify         bool          // Fase 4: comparación byte a byte contra el keeper
+	Dirs           bool          // Reportar además carpetas idénticas o contenidas en otra (ver findDirs)
+	Hasher         hasher.Hasher // Algoritmo de hash (nil = xxhash)
+	Cache          *cache.Cache  // Caché persistente de hashes (nil = desactivada)
+	Progress       ProgressFunc  // Eventos de progreso (nil = sin avisos)
+
+	// FS a escanear y hashear (nil = disco local). Fuera del disco no se usa la
+	// caché: sus claves (dispositivo, inodo) solo identifican archivos en disco.
+	FS fs.FS
 }
 
 type Stats struct {
 	TotalFilesScanned int64
-	FilesByHash       map[uint64]*entities.FileGroup
+	Groups            []*entities.FileGroup // [0] de cada grupo es el Keeper
+	Dirs              []*entities.DirGroup  // Solo con Options.Dirs
 	DuplicatesCount   int64
 	Duration          time.Duration
+	Errors            []entities.PathError // Archivos que no se pudieron leer (escaneo incompleto)
+	Interrupted       bool                 // Cancelado antes de terminar: resultados parciales
 }
 
 type Runner struct {
-	opts Options
+	opts     Options
+	archives *archive.FS          // Con Archives: de donde se leen los miembros (ver fsOf)
+	errs     []entities.PathError // Solo se escribe desde los consumidores (una goroutine)
 }
 
 func New(opts Options) *Runner {
-	return &Runner{opts: opts}
+	if opts.Hasher == nil {
+		opts.Hasher, _ = hasher.New(hasher.AlgoXXHash)
+	}
+	if opts.FS == nil {
+		opts.FS = vfs.OS
+	}
+	if opts.FS != vfs.OS {
+		opts.Cache = nil
+	}
+	r := &Runner{opts: opts}
+	if opts.Archives {
+		r.archives = archive.NewFS(opts.FS)
+	}
+	return r
 }
 
-func (r *Runner) Run(rootDir string) (*Stats, error) {
+// Run ejecuta el pipeline completo sobre una o varias raíces.
+// No escribe en consola: el avance se publica como eventos en Options.Progress.
+// Si ctx se cancela, los workers se detienen y se devuelven estadísticas parciales
+// (Interrupted = true) junto con ctx.Err(): los grupos reportados son duplicados
+// reales, pero puede faltar alguno.
+func (r *Runner) Run(ctx context.Context, roots ...string) (*Stats, error) {
 	start := time.Now()
+	r.errs = nil
 
 	// --- PASO 1: SCANNER ---
-	fmt.Println("🔍 Fase 1: Escaneando sistema de archivos...")
-	sc := scanner.New(scanner.Config{
-		MinSize:  r.opts.MinSize,
-		Excludes: r.opts.Excludes,
-	})
-
-	filesBySize, err := sc.Scan(rootDir)
-	if err != nil {
+	r.emit(Event{Kind: EventPhaseStarted, Phase: entities.PhaseScan})
+	var discovered int64
+	var walked []string
+	cfg := r.scanConfig(&discovered)
+	onRoot := cfg.OnRoot
+	cfg.OnRoot = func(root string) {
+		walked = append(walked, root)
+		onRoot(root)
+	}
+	sc := scanner.New(cfg)
+
+	filesBySize, err := sc.Scan(ctx, roots...)
+	if err != nil && ctx.Err() == nil {
 		return nil, fmt.Errorf("fallo en scanner: %w", err)
 	}
+	for _, e := range sc.Errors() {
+		r.addError(e)
+	}
 
-	var initialCandidates []string
+	var initialCandidates []*entities.FileInfo
 	var totalScanned int64
 	for _, group := range filesBySize {
 		totalScanned += group.Count
 		if group.Count > 1 {
-			for _, f := range group.Files {
-				initialCandidates = append(initialCandidates, f.Path)
-			}
+			initialCandidates = append(initialCandidates, group.Files...)
 		}
 	}
-	fmt.Printf("   -> %d archivos encontrados. %d candidatos por tamaño.\n", totalScanned, len(initialCandidates))
+	r.finishPhase(ctx, Event{Phase: entities.PhaseScan, Files: totalScanned, Candidates: int64(len(initialCandidates))})
+	if err := ctx.Err(); err != nil {
+		return r.stats(ctx, start, totalScanned, nil), err
+	}
 
 	// --- PASO 2: PRE-HASHING ---
-	fmt.Println("🔍 Fase 2: Pre-Hashing (4KB check)...")
-	preHashGroups := r.processPreHash(initialCandidates)
+	preHashGroups, preHits := r.processPreHash(ctx, initialCandidates)
 
-	var finalCandidates []string
-	for _, paths := range preHashGroups {
-		if len(paths) > 1 {
-			finalCandidates = append(finalCandidates, paths...)
+	var finalCandidates []*entities.FileInfo
+	for _, files := range preHashGroups {
+		if len(files) > 1 {
+			finalCandidates = append(finalCandidates, files...)
 		}
 	}
-	fmt.Printf("\n   -> %d candidatos tras Pre-Hash.\n", len(finalCandidates))
+	r.finishPhase(ctx, Event{Phase: entities.PhasePreHash, Files: int64(len(initialCandidates)), Candidates: int64(len(finalCandidates)), CacheHits: preHits})
+	if err := ctx.Err(); err != nil {
+		return r.stats(ctx, start, totalScanned, nil), err
+	}
 
 	// --- PASO 3: FULL HASHING ---
-	fmt.Println("🔍 Fase 3: Hashing Completo (Verificación final)...")
-	finalGroups := r.processFullHash(finalCandidates)
-	fmt.Println("\n   -> Hashing terminado.")
-
-	// --- PASO 4: ORDENAR Y FINALIZAR ---
-	sortGroups(finalGroups, r.opts.Strategy)
+	finalGroups, fullHits := r.processFullHash(ctx, finalCandidates)
 
-	var dupesCount int64
+	// --- ORDENAR (Elección del Keeper) ---
+	groups := make([]*entities.FileGroup, 0, len(finalGroups))
+	var inGroups int64
 	for _, group := range finalGroups {
 		if group.Count > 1 {
-			dupesCount += group.Count - 1
+			groups = append(groups, group)
+			inGroups += group.Count
+		}
+	}
+	sortGroups(groups, r.opts.Strategy)
+	r.finishPhase(ctx, Event{Phase: entities.PhaseHash, Files: int64(len(finalCandidates)), Candidates: inGroups, CacheHits: fullHits})
+
+	// --- PASO 4: VERIFICACIÓN BYTE A BYTE (Opcional) ---
+	if r.opts.Verify && ctx.Err() == nil {
+		total := int64(len(groups))
+		var splits int
+		groups, splits = r.verifyGroups(ctx, groups)
+		r.finishPhase(ctx, Event{Phase: entities.PhaseVerify, Files: total, Candidates: int64(len(groups)), Splits: splits})
+	}
+
+	stats := r.stats(ctx, start, totalScanned, groups)
+
+	// --- PASO 5: CARPETAS (Opcional) ---
+	// Con resultados parciales no se sabe qué carpetas son copias completas
+	if r.opts.Dirs && ctx.Err() == nil {
+		scanned := make([]*entities.FileInfo, 0, totalScanned)
+		for _, group := range filesBySize {
+			scanned = append(scanned, group.Files...)
 		}
+		stats.Dirs = r.findDirs(walked, sc.Dirs(), scanned, groups, sc.Incomplete)
+		stats.Duration = time.Since(start)
+	}
+	return stats, ctx.Err()
+}
+
+// scanConfig traslada los filtros de Options al scanner, con los avisos de
+// progreso del escaneo (discovered cuenta los archivos entre varias llamadas).
+func (r *Runner) scanConfig(discovered *int64) scanner.Config {
+	return scanner.Config{
+		MinSize:        r.opts.MinSize,
+		MaxSize:        r.opts.MaxSize,
+		NewerThan:      r.opts.NewerThan,
+		OlderThan:      r.opts.OlderThan,
+		Extensions:     r.opts.Extensions,
+		ExcludeExts:    r.opts.ExcludeExts,
+		Excludes:       r.opts.Excludes,
+		Includes:       r.opts.Includes,
+		ExcludeRegex:   r.opts.ExcludeRegex,
+		IncludeRegex:   r.opts.IncludeRegex,
+		IgnoreFiles:    r.opts.IgnoreFiles,
+		FollowSymlinks: r.opts.FollowSymlinks,
+		OneFileSystem:  r.opts.OneFileSystem,
+		Archives:       r.opts.Archives,
+		References:     r.opts.References,
+		FS:             r.opts.FS,
+		OnRoot: func(root string) {
+			r.emit(Event{Kind: EventRootStarted, Phase: entities.PhaseScan, Path: root})
+		},
+		OnFile: func(f *entities.FileInfo) {
+			*discovered++
+			r.emit(Event{Kind: EventFilesDiscovered, Phase: entities.PhaseScan, Path: f.Path, Files: *discovered, Bytes: f.Size})
+		},
+	}
+}
+
+// finishPhase publica el fin de una fase, marcando si se interrumpió.
+func (r *Runner) finishPhase(ctx context.Context, e Event) {
+	e.Kind = EventPhaseFinished
+	e.Interrupted = ctx.Err() != nil
+	r.emit(e)
+}
+
+// stats arma el resultado final (o parcial, si se canceló).
+func (r *Runner) stats(ctx context.Context, start time.Time, totalScanned int64, groups []*entities.FileGroup) *Stats {
+	var dupesCount int64
+	for _, group := range groups {
+		dupesCount += group.Count - 1
 	}
 
 	return &Stats{
 		TotalFilesScanned: totalScanned,
-		FilesByHash:       finalGroups,
+		Groups:            groups,
 		DuplicatesCount:   dupesCount,
 		Duration:          time.Since(start),
-	}, nil
+		Errors:            r.errs,
+		Interrupted:       ctx.Err() != nil,
+	}
 }
 
 // processPreHash: Optimizada para velocidad bruta.
-func (r *Runner) processPreHash(paths []string) map[uint64][]string {
-	type job struct{ path string }
+func (r *Runner) processPreHash(ctx context.Context, files []*entities.FileInfo) (map[entities.Digest][]*entities.FileInfo, int64) {
+	type job struct {
+		file  *entities.FileInfo
+		batch []*entities.FileInfo // Miembros de un mismo tar comprimido (ver splitSequential)
+	}
 	type result struct {
-		path string
-		hash uint64
-		err  error
+		file   *entities.FileInfo
+		hash   entities.Digest
+		cached bool
+		err    error
 	}
 
+	var totalBytes int64
+	for _, f := range files {
+		totalBytes += min(f.Size, hasher.PreHashSize)
+	}
+	r.emit(Event{Kind: EventPhaseStarted, Phase: entities.PhasePreHash, Total: int64(len(files)), TotalBytes: totalBytes})
+
 	// Restauramos buffer completo para evitar bloqueo de workers
-	jobs := make(chan job, len(paths))
-	results := make(chan result, len(paths))
+	jobs := make(chan job, len(files))
+	results := make(chan result, len(files))
 
 	numWorkers := runtime.NumCPU()
 	var wg sync.WaitGroup
@@ -127,15 +273,41 @@ func (r *Runner) processPreHash(paths []string) map[uint64][]string {
 		go func() {
 			defer wg.Done()
 			for j := range jobs {
-				h, err := hasher.HashFirstBlock(j.path)
-				results <- result{j.path, h, err}
+				if ctx.Err() != nil {
+					continue // Cancelado: vaciamos la cola sin trabajar
+				}
+				if j.batch != nil {
+					r.eachMember(ctx, j.batch, func(f *entities.FileInfo, fsys fs.FS, err error) {
+						var h entities.Digest
+						if err == nil {
+							h, err = r.opts.Hasher.HashFirstBlock(fsys, f.Path)
+						}
+						results <- result{f, h, false, err}
+					})
+					continue
+				}
+				if r.opts.Cache != nil {
+					if pre, _, ok := r.opts.Cache.Lookup(j.file); ok && pre != "" {
+						results <- result{j.file, pre, true, nil}
+						continue
+					}
+				}
+				h, err := r.opts.Hasher.HashFirstBlock(r.fsOf(j.file), j.file.Path)
+				if err == nil && r.opts.Cache != nil {
+					r.opts.Cache.StorePreHash(j.file, h)
+				}
+				results <- result{j.file, h, false, err}
 			}
 		}()
 	}
 
 	// Llenamos la cola a máxima velocidad
-	for _, p := range paths {
-		jobs <- job{p}
+	rest, batches := r.splitSequential(files)
+	for _, b := range batches {
+		jobs <- job{batch: b}
+	}
+	for _, f := range rest {
+		jobs <- job{file: f}
 	}
 	close(jobs)
 
@@ -145,35 +317,56 @@ func (r *Runner) processPreHash(paths []string) map[uint64][]string {
 		close(results)
 	}()
 
-	groups := make(map[uint64][]string)
-	processed := 0
-	
+	groups := make(map[entities.Digest][]*entities.FileInfo)
+	var processed, bytesRead int64
+	var hits int64
+
 	// Consumidor sin bloqueos
 	for res := range results {
 		processed++
-		if processed%200 == 0 { // Menos I/O a consola
-			fmt.Print(".")
+		bytesRead += min(res.file.Size, hasher.PreHashSize)
+		r.emit(Event{Kind: EventProgress, Phase: entities.PhasePreHash, Path: res.file.Path,
+			Files: processed, Total: int64(len(files)), Bytes: bytesRead, TotalBytes: totalBytes})
+		if res.cached {
+			hits++
 		}
-		if res.err == nil {
-			groups[res.hash] = append(groups[res.hash], res.path)
+		if res.err != nil {
+			if !canceled(ctx, res.err) {
+				r.recordError(entities.PhasePreHash, res.file.Path, res.err)
+			}
+			continue
 		}
+		groups[res.hash] = append(groups[res.hash], res.file)
 	}
-	return groups
+	return groups, hits
 }
 
 // processFullHash: Workers + Stat eficiente
-func (r *Runner) processFullHash(paths []string) map[uint64]*entities.FileGroup {
-	type job struct{ path string }
+func (r *Runner) processFullHash(ctx context.Context, files []*entities.FileInfo) (map[entities.Digest]*entities.FileGroup, int64) {
+	type job struct {
+		file  *entities.FileInfo
+		batch []*entities.FileInfo // Miembros de un mismo tar comprimido (ver splitSequential)
+	}
 	type result struct {
-		path  string
-		hash  uint64
-		stats hasher.FileStats
-		err   error
+		file   *entities.FileInfo
+		hash   entities.Digest
+		stats  hasher.FileStats
+		cached bool
+		err    error
 	}
 
+	var totalBytes int64
+	for _, f := range files {
+		totalBytes += f.Size
+	}
+	r.emit(Event{Kind: EventPhaseStarted, Phase: entities.PhaseHash, Total: int64(len(files)), TotalBytes: totalBytes})
+
 	// Restauramos buffer completo
-	jobs := make(chan job, len(paths))
-	results := make(chan result, len(paths))
+	jobs := make(chan job, len(files))
+	results := make(chan result, len(files))
+
+	// Bytes leídos en vivo: el progreso avanza también dentro de archivos grandes
+	var bytesRead atomic.Int64
 
 	numWorkers := runtime.NumCPU()
 	var wg sync.WaitGroup
@@ -183,14 +376,44 @@ func (r *Runner) processFullHash(paths []string) map[uint64]*entities.FileGroup
 		go func() {
 			defer wg.Done()
 			for j := range jobs {
-				h, stats, err := hasher.HashFile(j.path)
-				results <- result{j.path, h, stats, err}
+				if ctx.Err() != nil {
+					continue
+				}
+				if j.batch != nil {
+					r.eachMember(ctx, j.batch, func(f *entities.FileInfo, fsys fs.FS, err error) {
+						var h entities.Digest
+						var stats hasher.FileStats
+						if err == nil {
+							h, stats, err = r.opts.Hasher.HashFile(ctx, fsys, f.Path, &bytesRead)
+						}
+						results <- result{f, h, stats, false, err}
+					})
+					continue
+				}
+				if r.opts.Cache != nil {
+					if _, full, ok := r.opts.Cache.Lookup(j.file); ok && full != "" {
+						// Hit: los stats del escaneo son los que validaron la entrada
+						stats := hasher.FileStats{Size: j.file.Size, DeviceID: j.file.DeviceID, Inode: j.file.Inode}
+						bytesRead.Add(j.file.Size)
+						results <- result{j.file, full, stats, true, nil}
+						continue
+					}
+				}
+				h, stats, err := r.opts.Hasher.HashFile(ctx, r.fsOf(j.file), j.file.Path, &bytesRead)
+				if err == nil && r.opts.Cache != nil {
+					r.opts.Cache.StoreFullHash(j.file, h)
+				}
+				results <- result{j.file, h, stats, false, err}
 			}
 		}()
 	}
 
-	for _, p := range paths {
-		jobs <- job{p}
+	rest, batches := r.splitSequential(files)
+	for _, b := range batches {
+		jobs <- job{batch: b}
+	}
+	for _, f := range rest {
+		jobs <- job{file: f}
 	}
 	close(jobs)
 
@@ -199,38 +422,77 @@ func (r *Runner) processFullHash(paths []string) map[uint64]*entities.FileGroup
 		close(results)
 	}()
 
-	groups := make(map[uint64]*entities.FileGroup)
-	processed := 0
+	groups := make(map[entities.Digest]*entities.FileGroup)
+	var processed int64
+	var hits int64
 
-	for res := range results {
-		processed++
-		if processed%50 == 0 { // Menos print para no saturar stdout
-			fmt.Print("#")
+	progress := func(path string) {
+		r.emit(Event{Kind: EventProgress, Phase: entities.PhaseHash, Path: path,
+			Files: processed, Total: int64(len(files)), Bytes: bytesRead.Load(), TotalBytes: totalBytes})
+	}
+	ticker := time.NewTicker(progressTick)
+	defer ticker.Stop()
+
+	for {
+		var res result
+		select {
+		case <-ticker.C:
+			progress("")
+			continue
+		case next, ok := <-results:
+			if !ok {
+				return groups, hits
+			}
+			res = next
 		}
+		processed++
+		progress(res.file.Path)
 
 		if res.err != nil {
+			if !canceled(ctx, res.err) {
+				r.recordError(entities.PhaseHash, res.file.Path, res.err)
+			}
 			continue
 		}
+		if res.cached {
+			hits++
+		}
 		if _, exists := groups[res.hash]; !exists {
 			groups[res.hash] = &entities.FileGroup{}
 		}
 
-		// Stat solo si es estrictamente necesario para la estrategia
-		var modTime time.Time
-		if r.opts.Strategy == KeepOldest || r.opts.Strategy == KeepNewest {
-			if info, err := os.Stat(res.path); err == nil {
-				modTime = info.ModTime()
-			}
-		}
-
+		// El mtime ya viene del escaneo (Fase 1): no hace falta otro Stat
 		groups[res.hash].Add(&entities.FileInfo{
-			Path:     res.path,
-			Hash:     res.hash,
-			Size:     res.stats.Size,
-			DeviceID: res.stats.DeviceID,
-			Inode:    res.stats.Inode,
-			ModTime:  modTime,
+			Path:       res.file.Path,
+			Hash:       res.hash,
+			Size:       res.stats.Size,
+			DeviceID:   res.stats.DeviceID,
+			Inode:      res.stats.Inode,
+			ModTime:    res.file.ModTime,
+			ChangeTime: res.file.ChangeTime,
+			Reference:  res.file.Reference,
+			Archive:    res.file.Archive,
 		})
 	}
-	return groups
+}
+
+// recordError anota un archivo que no se pudo leer. Si el error trae su propia
+// ruta (p.ej. el Keeper durante la verificación), esa es la que se reporta.
+func (r *Runner) recordError(phase entities.Phase, path string, err error) {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		path = pe.Path
+	}
+	r.addError(entities.NewPathError(phase, path, err))
+}
+
+// addError guarda el error para Stats y lo publica como evento.
+func (r *Runner) addError(e entities.PathError) {
+	r.errs = append(r.errs, e)
+	r.emit(Event{Kind: EventError, Phase: e.Phase, Path: e.Path, Err: &e})
+}
+
+// canceled indica si err es consecuencia de la cancelación (no un fallo del archivo).
+func canceled(ctx context.Context, err error) bool {
+	return ctx.Err() != nil && errors.Is(err, ctx.Err())
 }
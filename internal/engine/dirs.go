@@ -0,0 +1,439 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/soyunomas/dupedetector/internal/entities"
+)
+
+// dirNode es una carpeta del árbol construido a partir de los archivos escaneados.
+type dirNode struct {
+	path     string
+	parent   *dirNode
+	children []*dirNode
+	files    []*entities.FileInfo
+	depth    int
+
+	digest    entities.Digest
+	count     int64 // Archivos bajo la carpeta (recursivo)
+	size      int64
+	reference bool // Contiene archivos de referencia
+	partial   bool // Ella o alguna subcarpeta tiene algo sin agregar
+
+	role           dirRole
+	keeperBelow    bool           // Alguna subcarpeta se conserva en otro grupo
+	redundantBelow bool           // Alguna subcarpeta es redundante
+	ids            map[string]int // Multiconjunto de contenidos (se calcula bajo demanda)
+}
+
+// dirRole es el papel de una carpeta en un grupo ya reportado.
+type dirRole int
+
+const (
+	roleNone dirRole = iota
+	roleKeeper
+	roleRedundant
+)
+
+// dirTree indexa las carpetas y la identidad de contenido de cada archivo.
+type dirTree struct {
+	nodes  map[string]*dirNode
+	ids    map[*entities.FileInfo]string
+	copies map[string][]*entities.FileInfo // Archivos (sueltos) con cada contenido
+}
+
+// findDirs: modo -dirs. Calcula un digest tipo Merkle por carpeta a partir de los
+// grupos finales y reporta árboles idénticos y carpetas cuyo contenido completo
+// está dentro de otra. Los nombres de archivo no cuentan (salvo en los vacíos, cuyo
+// único contenido es el nombre); la estructura sí, subcarpetas vacías incluidas.
+// Nunca es redundante una carpeta incompleta (ver scanner.Incomplete), una con
+// referencias ni una sin datos (solo archivos vacíos o carpetas vacías).
+func (r *Runner) findDirs(roots, dirs []string, scanned []*entities.FileInfo, groups []*entities.FileGroup, incomplete func(string) bool) []*entities.DirGroup {
+	t := buildDirTree(roots, dirs, scanned, groups, incomplete)
+
+	// Las más superficiales primero: un árbol redundante se reporta una vez, no cada subcarpeta
+	nodes := make([]*dirNode, 0, len(t.nodes))
+	for _, n := range t.nodes {
+		if n.size > 0 {
+			nodes = append(nodes, n)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].depth != nodes[j].depth {
+			return nodes[i].depth < nodes[j].depth
+		}
+		return nodes[i].path < nodes[j].path
+	})
+
+	return append(r.identicalDirs(nodes), t.subsetDirs(nodes)...)
+}
+
+// identicalDirs agrupa las carpetas por digest.
+func (r *Runner) identicalDirs(nodes []*dirNode) []*entities.DirGroup {
+	byDigest := make(map[entities.Digest][]*dirNode)
+	var order []entities.Digest
+	for _, n := range nodes {
+		if len(byDigest[n.digest]) == 0 {
+			order = append(order, n.digest)
+		}
+		byDigest[n.digest] = append(byDigest[n.digest], n)
+	}
+
+	var out []*entities.DirGroup
+	for _, d := range order {
+		members := byDigest[d]
+		if len(members) < 2 {
+			continue
+		}
+		// Las que ya van dentro de una carpeta redundante no se reportan de nuevo
+		var alive []*dirNode
+		for _, n := range members {
+			if !n.insideRedundant() {
+				alive = append(alive, n)
+			}
+		}
+		if len(alive) < 2 {
+			continue
+		}
+		keeper := r.pickKeeper(alive)
+		if keeper == nil {
+			continue
+		}
+
+		var redundant []*dirNode
+		for _, n := range alive {
+			if n != keeper && n.removable() {
+				redundant = append(redundant, n)
+			}
+		}
+		if len(redundant) == 0 {
+			continue
+		}
+		out = append(out, newDirGroup(entities.DirIdentical, keeper, redundant))
+	}
+	return out
+}
+
+// pickKeeper prefiere una carpeta que ya se conserva (o está dentro de una que se
+// conserva), luego las referencias y luego la estrategia, como con los archivos.
+// Una carpeta con algo redundante dentro no sirve: le faltará contenido.
+func (r *Runner) pickKeeper(members []*dirNode) *dirNode {
+	var intact, kept []*dirNode
+	for _, n := range members {
+		if n.redundantBelow {
+			continue
+		}
+		intact = append(intact, n)
+		if n.insideKeeper() {
+			kept = append(kept, n)
+		}
+	}
+	if len(kept) > 0 {
+		intact = kept
+	}
+	if len(intact) == 0 {
+		return nil
+	}
+
+	files := make([]*entities.FileInfo, len(intact))
+	byFile := make(map[*entities.FileInfo]*dirNode, len(intact))
+	for i, n := range intact {
+		files[i] = &entities.FileInfo{Path: n.path, Reference: n.reference, ModTime: n.modTime()}
+		byFile[files[i]] = n
+	}
+	sortGroups([]*entities.FileGroup{{Count: int64(len(files)), Files: files}}, r.opts.Strategy)
+	return byFile[files[0]]
+}
+
+// subsetDirs busca, para cada carpeta completa, la carpeta más pequeña (que no sea
+// ancestro suyo) que contiene todos sus contenidos y tiene más archivos.
+// Las redundantes con el mismo Keeper se reportan juntas.
+func (t *dirTree) subsetDirs(nodes []*dirNode) []*entities.DirGroup {
+	var out []*entities.DirGroup
+	byKeeper := make(map[*dirNode]*entities.DirGroup)
+	for _, s := range nodes {
+		if !s.removable() {
+			continue
+		}
+		y := t.container(s)
+		if y == nil {
+			continue
+		}
+		if g, ok := byKeeper[y]; ok {
+			s.setRole(roleRedundant)
+			g.Redundant = append(g.Redundant, s.info())
+			continue
+		}
+		g := newDirGroup(entities.DirSubset, y, []*dirNode{s})
+		byKeeper[y] = g
+		out = append(out, g)
+	}
+	return out
+}
+
+// container devuelve la carpeta más pequeña que contiene todo s, o nil.
+// Los candidatos son los ancestros de las copias del contenido más escaso de s.
+func (t *dirTree) container(s *dirNode) *dirNode {
+	need := s.multiset(t)
+	rarest := ""
+	for id := range need {
+		if strings.HasPrefix(id, "u:") {
+			return nil // Contenido único: no hay copia en ningún sitio
+		}
+		if rarest == "" || len(t.copies[id]) < len(t.copies[rarest]) || (len(t.copies[id]) == len(t.copies[rarest]) && id < rarest) {
+			rarest = id
+		}
+	}
+
+	var best *dirNode
+	for _, f := range t.copies[rarest] {
+		for y := t.nodes[filepath.Dir(f.Path)]; y != nil; y = y.parent {
+			if y == s || s.isAncestor(y) || y.isAncestor(s) {
+				break // Una copia dentro de s, o un ancestro de s: de aquí hacia arriba nada sirve
+			}
+			if y.count <= s.count {
+				continue
+			}
+			if y.role == roleRedundant || y.insideRedundant() {
+				break
+			}
+			if y.redundantBelow {
+				continue // Le faltará contenido; un ancestro aún puede servir
+			}
+			if contains(y.multiset(t), need) {
+				if best == nil || y.count < best.count || (y.count == best.count && y.path < best.path) {
+					best = y
+				}
+				break // Los ancestros de y son más grandes
+			}
+		}
+	}
+	return best
+}
+
+// contains indica si el multiconjunto have incluye a need.
+func contains(have, need map[string]int) bool {
+	for id, n := range need {
+		if have[id] < n {
+			return false
+		}
+	}
+	return true
+}
+
+// newDirGroup asigna los roles y arma el grupo reportado.
+func newDirGroup(kind entities.DirGroupKind, keeper *dirNode, redundant []*dirNode) *entities.DirGroup {
+	if keeper.role == roleNone {
+		keeper.setRole(roleKeeper)
+	}
+	g := &entities.DirGroup{Kind: kind, Keeper: keeper.info()}
+	for _, n := range redundant {
+		n.setRole(roleRedundant)
+		g.Redundant = append(g.Redundant, n.info())
+	}
+	return g
+}
+
+// buildDirTree arma el árbol de carpetas bajo las raíces y calcula sus digests.
+func buildDirTree(roots, dirs []string, scanned []*entities.FileInfo, groups []*entities.FileGroup, incomplete func(string) bool) *dirTree {
+	t := &dirTree{
+		nodes:  make(map[string]*dirNode),
+		ids:    make(map[*entities.FileInfo]string, len(scanned)),
+		copies: make(map[string][]*entities.FileInfo),
+	}
+	isRoot := make(map[string]bool, len(roots))
+	for _, r := range roots {
+		isRoot[filepath.Clean(r)] = true
+	}
+
+	// Identidad de contenido: el grupo final. Tras -verify un hash puede quedar
+	// partido en varios grupos, así que entonces se distinguen por su Keeper.
+	// Los archivos de los grupos son copias (con Hash) de los escaneados.
+	hashed := make(map[string]*entities.FileInfo)
+	perHash := make(map[entities.Digest]int, len(groups))
+	for _, g := range groups {
+		perHash[g.Files[0].Hash]++
+	}
+	for _, g := range groups {
+		id := "h:" + string(g.Files[0].Hash)
+		if perHash[g.Files[0].Hash] > 1 {
+			id += "@" + g.Files[0].Path
+		}
+		for _, f := range g.Files {
+			t.ids[f] = id
+			hashed[f.Path] = f
+		}
+	}
+
+	var node func(dir string) *dirNode
+	node = func(dir string) *dirNode {
+		if n, ok := t.nodes[dir]; ok {
+			return n
+		}
+		n := &dirNode{path: dir, partial: incomplete(dir)}
+		t.nodes[dir] = n
+		if parent := filepath.Dir(dir); !isRoot[dir] && parent != dir {
+			n.parent = node(parent)
+			n.parent.children = append(n.parent.children, n)
+		}
+		return n
+	}
+	for _, d := range dirs {
+		node(filepath.Clean(d))
+	}
+	for _, f := range scanned {
+		if f.Archive != "" {
+			continue // Los miembros de comprimidos no son archivos de la carpeta
+		}
+		if h, ok := hashed[f.Path]; ok {
+			f = h
+		}
+		id, ok := t.ids[f]
+		switch {
+		case f.Size == 0:
+			// Todos los vacíos tienen el mismo hash: lo que los distingue es el nombre
+			// (.gitkeep no es copia de .keep, __init__.py sí lo es de __init__.py)
+			id = "e:" + filepath.Base(f.Path)
+			t.ids[f] = id
+			t.copies[id] = append(t.copies[id], f)
+		case !ok:
+			id = "u:" + f.Path
+			t.ids[f] = id
+		default:
+			t.copies[id] = append(t.copies[id], f)
+		}
+		n := node(filepath.Dir(f.Path))
+		n.files = append(n.files, f)
+	}
+
+	// Profundidad y digest: los hijos antes que los padres
+	all := make([]*dirNode, 0, len(t.nodes))
+	for _, n := range t.nodes {
+		for p := n.parent; p != nil; p = p.parent {
+			n.depth++
+		}
+		all = append(all, n)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].depth > all[j].depth })
+	for _, n := range all {
+		t.summarize(n)
+	}
+	return t
+}
+
+// summarize calcula el digest y los totales de n (sus hijos ya están calculados).
+func (t *dirTree) summarize(n *dirNode) {
+	entries := make([]string, 0, len(n.files)+len(n.children))
+	for _, f := range n.files {
+		entries = append(entries, "f:"+t.ids[f])
+		n.count++
+		n.size += f.Size
+		n.reference = n.reference || f.Reference
+	}
+	for _, c := range n.children {
+		entries = append(entries, "d:"+string(c.digest))
+		n.count += c.count
+		n.size += c.size
+		n.reference = n.reference || c.reference
+	}
+	sort.Strings(entries)
+
+	h := sha256.New()
+	for _, e := range entries {
+		h.Write([]byte(e))
+		h.Write([]byte{'\n'})
+	}
+	n.digest = entities.Digest(hex.EncodeToString(h.Sum(nil)))
+}
+
+// multiset devuelve (y memoriza) los contenidos de n con su multiplicidad.
+func (n *dirNode) multiset(t *dirTree) map[string]int {
+	if n.ids != nil {
+		return n.ids
+	}
+	n.ids = make(map[string]int)
+	for _, f := range n.files {
+		n.ids[t.ids[f]]++
+	}
+	for _, c := range n.children {
+		for id, k := range c.multiset(t) {
+			n.ids[id] += k
+		}
+	}
+	return n.ids
+}
+
+// contents devuelve los archivos bajo n (recursivo).
+func (n *dirNode) contents(out []*entities.FileInfo) []*entities.FileInfo {
+	out = append(out, n.files...)
+	for _, c := range n.children {
+		out = c.contents(out)
+	}
+	return out
+}
+
+// removable: se puede proponer como redundante (completa, sin referencias,
+// sin roles propios ni debajo, y fuera de otra redundante).
+func (n *dirNode) removable() bool {
+	return !n.partial && !n.reference && n.role == roleNone && !n.keeperBelow && !n.redundantBelow && !n.insideRedundant()
+}
+
+func (n *dirNode) insideRedundant() bool {
+	for p := n.parent; p != nil; p = p.parent {
+		if p.role == roleRedundant {
+			return true
+		}
+	}
+	return false
+}
+
+// insideKeeper: n o un ancestro ya se conserva en otro grupo.
+func (n *dirNode) insideKeeper() bool {
+	for p := n; p != nil; p = p.parent {
+		if p.role == roleKeeper {
+			return true
+		}
+	}
+	return false
+}
+
+// isAncestor indica si n es ancestro (estricto) de d.
+func (n *dirNode) isAncestor(d *dirNode) bool {
+	for p := d.parent; p != nil; p = p.parent {
+		if p == n {
+			return true
+		}
+	}
+	return false
+}
+
+// setRole asigna el rol de n y lo anota en sus ancestros.
+func (n *dirNode) setRole(role dirRole) {
+	n.role = role
+	for p := n.parent; p != nil; p = p.parent {
+		if role == roleKeeper {
+			p.keeperBelow = true
+		} else {
+			p.redundantBelow = true
+		}
+	}
+}
+
+// modTime de una carpeta: la del archivo más reciente que contiene (para -keep oldest/newest).
+func (n *dirNode) modTime() (latest time.Time) {
+	for _, f := range n.contents(nil) {
+		if f.ModTime.After(latest) {
+			latest = f.ModTime
+		}
+	}
+	return latest
+}
+
+func (n *dirNode) info() *entities.DirInfo {
+	return &entities.DirInfo{Path: n.path, Digest: n.digest, Files: n.count, Size: n.size, Reference: n.reference, Contents: n.contents(nil)}
+}
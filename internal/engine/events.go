@@ -0,0 +1,46 @@
+package engine
+
+import "github.com/soyunomas/dupedetector/internal/entities"
+
+// EventKind identifica el tipo de evento de progreso.
+type EventKind int
+
+const (
+	EventPhaseStarted    EventKind = iota // Comienza una fase (Total/TotalBytes = trabajo previsto)
+	EventRootStarted                      // El scanner entra en una raíz (Path)
+	EventFilesDiscovered                  // Un archivo más aceptado por el scanner (Files = total hasta ahora)
+	EventProgress                         // Un elemento procesado (Files/Total, Bytes/TotalBytes)
+	EventPhaseFinished                    // Fin de fase (Files = procesados, Candidates = siguen adelante)
+	EventError                            // Una ruta no se pudo leer (Err)
+)
+
+// Event es un aviso de progreso del pipeline. Según Kind, solo algunos campos tienen valor.
+type Event struct {
+	Kind  EventKind
+	Phase entities.Phase
+	Path  string
+
+	Files      int64 // Elementos procesados (archivos; grupos en la fase verify)
+	Total      int64 // Elementos previstos en la fase
+	Bytes      int64 // Bytes leídos
+	TotalBytes int64 // Bytes previstos en la fase
+
+	Candidates  int64 // Fin de fase: archivos (o grupos) que pasan a la siguiente
+	CacheHits   int64 // Fin de fase: digests obtenidos de la caché
+	Splits      int   // Fin de verify: grupos divididos por colisión de hash
+	Interrupted bool  // Fin de fase: se canceló antes de terminar
+
+	Err *entities.PathError
+}
+
+// ProgressFunc recibe los eventos de Run. Siempre se invoca desde la goroutine
+// que ejecuta Run, nunca en paralelo, así que no necesita sincronización.
+// Debe ser rápida: un evento por archivo puede significar millones de llamadas.
+type ProgressFunc func(Event)
+
+// emit publica un evento si hay alguien escuchando.
+func (r *Runner) emit(e Event) {
+	if r.opts.Progress != nil {
+		r.opts.Progress(e)
+	}
+}
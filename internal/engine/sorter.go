@@ -8,7 +8,7 @@ import (
 
 // sortGroups organiza los archivos dentro de cada grupo según la estrategia.
 // El objetivo es que el archivo en la posición [0] sea el "Keeper" (Original).
-func sortGroups(groups map[uint64]*entities.FileGroup, strategy KeepStrategy) {
+func sortGroups(groups []*entities.FileGroup, strategy KeepStrategy) {
 	for _, group := range groups {
 		if group.Count < 2 {
 			continue
@@ -20,8 +20,18 @@ func sortGroups(groups map[uint64]*entities.FileGroup, strategy KeepStrategy) {
 			f1 := group.Files[i]
 			f2 := group.Files[j]
 
+			// Los miembros de archivos comprimidos, siempre detrás: no se pueden
+			// enlazar ni restaurar, así que un archivo suelto es mejor Keeper
+			if (f1.Archive == "") != (f2.Archive == "") {
+				return f1.Archive == ""
+			}
+			// Las referencias (solo lectura) van delante: son los mejores Keepers
+			if f1.Reference != f2.Reference {
+				return f1.Reference
+			}
+
 			switch strategy {
-			
+
 			case KeepShortestPath:
 				// [0] debe ser el más corto
 				if len(f1.Path) != len(f2.Path) {
@@ -50,7 +60,7 @@ func sortGroups(groups map[uint64]*entities.FileGroup, strategy KeepStrategy) {
 			// --- CRITERIOS DE DESEMPATE (Tie-Breakers) ---
 			// Si las reglas principales (longitud o fecha) son iguales,
 			// necesitamos un determinismo absoluto.
-			
+
 			// 1. Longitud de ruta (si no fue el criterio principal)
 			if len(f1.Path) != len(f2.Path) {
 				if strategy == KeepLongestPath {
@@ -0,0 +1,259 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"runtime"
+	"sync"
+
+	"github.com/soyunomas/dupedetector/internal/archive"
+	"github.com/soyunomas/dupedetector/internal/entities"
+	"github.com/soyunomas/dupedetector/internal/hasher"
+)
+
+// verifyGroups: Fase 4. Compara cada miembro contra el Keeper byte a byte.
+// Un hash de 64 bits es probabilidad, no prueba: los grupos que difieran se dividen.
+// Devuelve los grupos verificados y cuántos grupos originales tuvieron que dividirse.
+func (r *Runner) verifyGroups(ctx context.Context, groups []*entities.FileGroup) ([]*entities.FileGroup, int) {
+	type result struct {
+		groups []*entities.FileGroup
+		split  bool
+		errs   []error
+		bytes  int64
+	}
+
+	// El avance en bytes es el tamaño total de los grupos verificados
+	var totalBytes int64
+	for _, g := range groups {
+		totalBytes += g.Files[0].Size * g.Count
+	}
+	r.emit(Event{Kind: EventPhaseStarted, Phase: entities.PhaseVerify, Total: int64(len(groups)), TotalBytes: totalBytes})
+
+	// Miembros de tar comprimidos contra su Keeper: una pasada por comprimido
+	memo := r.verifyMembers(ctx, groups)
+	check := func(keeper, f *entities.FileInfo, bufA, bufB []byte) (bool, error) {
+		if c, ok := memo[f.Path]; ok && c.keeper == keeper.Path {
+			return c.equal, c.err
+		}
+		return sameContent(ctx, r.fsOf(keeper), keeper.Path, r.fsOf(f), f.Path, bufA, bufB)
+	}
+
+	jobs := make(chan *entities.FileGroup, len(groups))
+	results := make(chan result, len(groups))
+
+	numWorkers := runtime.NumCPU()
+	var wg sync.WaitGroup
+
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Memoria acotada: dos bloques por worker, sin importar el tamaño del archivo
+			bufA := make([]byte, hasher.BlockSize)
+			bufB := make([]byte, hasher.BlockSize)
+			for g := range jobs {
+				if ctx.Err() != nil {
+					continue // Sin verificar no hay prueba: el grupo no se reporta
+				}
+				verified, split, errs := verifyGroup(r.fsOf, g, check, bufA, bufB)
+				results <- result{verified, split, errs, g.Files[0].Size * g.Count}
+			}
+		}()
+	}
+
+	for _, g := range groups {
+		jobs <- g
+	}
+	close(jobs)
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var out []*entities.FileGroup
+	splits := 0
+	var processed, bytesRead int64
+
+	for res := range results {
+		processed++
+		bytesRead += res.bytes
+		r.emit(Event{Kind: EventProgress, Phase: entities.PhaseVerify,
+			Files: processed, Total: int64(len(groups)), Bytes: bytesRead, TotalBytes: totalBytes})
+		if res.split {
+			splits++
+		}
+		for _, err := range res.errs {
+			if !canceled(ctx, err) {
+				r.recordError(entities.PhaseVerify, "", err)
+			}
+		}
+		out = append(out, res.groups...)
+	}
+	return out, splits
+}
+
+// verifyGroup divide un grupo (ya ordenado) en subgrupos de contenido idéntico.
+// El orden se conserva, así que el [0] de cada subgrupo sigue siendo su mejor Keeper.
+// Los archivos que no se pueden leer se descartan (sin lectura no hay prueba) y se devuelven sus errores.
+func verifyGroup(fsOf func(*entities.FileInfo) fs.FS, g *entities.FileGroup, check compareFunc, bufA, bufB []byte) ([]*entities.FileGroup, bool, []error) {
+	var out []*entities.FileGroup
+	var errs []error
+	split := false
+	pending := g.Files
+
+	for len(pending) > 1 {
+		keeper := pending[0]
+		if _, err := fs.Stat(fsOf(keeper), keeper.Path); err != nil {
+			errs = append(errs, err)
+			pending = pending[1:]
+			continue
+		}
+
+		same := &entities.FileGroup{Verified: true}
+		same.Add(keeper)
+		var rest []*entities.FileInfo
+
+		for _, f := range pending[1:] {
+			// Hard link del Keeper: mismo inodo, mismo contenido
+			if f.Inode != 0 && f.DeviceID == keeper.DeviceID && f.Inode == keeper.Inode {
+				same.Add(f)
+				continue
+			}
+
+			equal, err := check(keeper, f, bufA, bufB)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if equal {
+				same.Add(f)
+			} else {
+				rest = append(rest, f)
+				split = true
+			}
+		}
+
+		if same.Count > 1 {
+			out = append(out, same)
+		}
+		pending = rest
+	}
+	return out, split, errs
+}
+
+// compareFunc compara el contenido de f con el del Keeper.
+type compareFunc func(keeper, f *entities.FileInfo, bufA, bufB []byte) (bool, error)
+
+// memberCheck es el resultado de comparar un miembro contra un Keeper concreto.
+type memberCheck struct {
+	keeper string
+	equal  bool
+	err    error
+}
+
+// verifyMembers compara de antemano cada miembro de tar comprimido con el Keeper
+// de su grupo, leyendo cada comprimido una sola vez. Solo cuando el Keeper es un
+// archivo suelto o de acceso directo: si también es un miembro de tar comprimido
+// (grupos solo de comprimidos), se compara después abriendo ambos.
+func (r *Runner) verifyMembers(ctx context.Context, groups []*entities.FileGroup) map[string]memberCheck {
+	keeperOf := make(map[string]*entities.FileInfo)
+	var members []*entities.FileInfo
+	for _, g := range groups {
+		keeper := g.Files[0]
+		if keeper.Archive != "" && archive.Sequential(keeper.Archive) {
+			continue
+		}
+		for _, f := range g.Files[1:] {
+			if f.Archive != "" {
+				keeperOf[f.Path] = keeper
+				members = append(members, f)
+			}
+		}
+	}
+	_, batches := r.splitSequential(members)
+	if len(batches) == 0 {
+		return nil
+	}
+
+	jobs := make(chan []*entities.FileInfo, len(batches))
+	for _, b := range batches {
+		jobs <- b
+	}
+	close(jobs)
+
+	memo := make(map[string]memberCheck, len(members))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < min(runtime.NumCPU(), len(batches)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bufA := make([]byte, hasher.BlockSize)
+			bufB := make([]byte, hasher.BlockSize)
+			for b := range jobs {
+				r.eachMember(ctx, b, func(f *entities.FileInfo, fsys fs.FS, err error) {
+					keeper := keeperOf[f.Path]
+					equal := false
+					if err == nil {
+						var fb fs.File
+						if fb, err = fsys.Open(f.Path); err == nil {
+							equal, err = sameAs(ctx, r.fsOf(keeper), keeper.Path, fb, bufA, bufB)
+						}
+					}
+					mu.Lock()
+					memo[f.Path] = memberCheck{keeper.Path, equal, err}
+					mu.Unlock()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	return memo
+}
+
+// sameContent compara dos archivos en streaming, bloque a bloque.
+// Cada uno se abre en su FS: el disco o, si es un miembro, su comprimido.
+func sameContent(ctx context.Context, fsA fs.FS, pathA string, fsB fs.FS, pathB string, bufA, bufB []byte) (bool, error) {
+	fb, err := fsB.Open(pathB)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+	return sameAs(ctx, fsA, pathA, fb, bufA, bufB)
+}
+
+// sameAs compara el archivo pathA con fb (ya abierto), bloque a bloque.
+func sameAs(ctx context.Context, fsys fs.FS, pathA string, fb io.Reader, bufA, bufB []byte) (bool, error) {
+	fa, err := fsys.Open(pathA)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+
+		if errA != nil && errA != io.EOF && errA != io.ErrUnexpectedEOF {
+			return false, errA
+		}
+		if errB != nil && errB != io.EOF && errB != io.ErrUnexpectedEOF {
+			return false, errB
+		}
+
+		if na != nb || !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+
+		// Ambos terminaron a la vez (na == nb ya está garantizado)
+		if errA != nil || errB != nil {
+			return errA != nil && errB != nil, nil
+		}
+	}
+}
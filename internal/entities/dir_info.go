@@ -0,0 +1,32 @@
+package entities
+
+// DirInfo resume una carpeta escaneada en modo -dirs.
+type DirInfo struct {
+	Path   string `json:"path"`
+	Digest Digest `json:"digest"` // Merkle: contenidos de sus archivos y estructura de subcarpetas (no los nombres)
+	Files  int64  `json:"files"`
+	Size   int64  `json:"size_bytes"`
+
+	// Reference: contiene archivos de una carpeta de referencia. Puede ser Keeper, nunca víctima
+	Reference bool `json:"reference,omitempty"`
+
+	// Contents: los archivos escaneados bajo la carpeta (recursivo), para revalidar antes de actuar
+	Contents []*FileInfo `json:"-"`
+}
+
+// DirGroupKind distingue los dos tipos de redundancia entre carpetas.
+type DirGroupKind string
+
+const (
+	// DirIdentical: árboles idénticos (mismo Digest)
+	DirIdentical DirGroupKind = "identical"
+	// DirSubset: todo el contenido de cada carpeta redundante está dentro del Keeper
+	DirSubset DirGroupKind = "subset"
+)
+
+// DirGroup es una carpeta que se conserva (Keeper) y las que sobran respecto a ella.
+type DirGroup struct {
+	Kind      DirGroupKind `json:"kind"`
+	Keeper    *DirInfo     `json:"keeper"`
+	Redundant []*DirInfo   `json:"redundant"`
+}
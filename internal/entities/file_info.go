@@ -4,21 +4,36 @@ import (
 	"time"
 )
 
+// Digest es el hash de contenido codificado en hexadecimal.
+// Su longitud depende del algoritmo (xxhash: 16 caracteres, sha256: 64...).
+type Digest string
+
 // FileInfo representa un archivo en disco con los metadatos necesarios.
 // Añadimos tags `json` para serialización.
 type FileInfo struct {
 	Path     string    `json:"path"`
 	Size     int64     `json:"size_bytes"`
-	Hash     uint64    `json:"hash"`
+	Hash     Digest    `json:"hash"`
 	ModTime  time.Time `json:"mod_time"`
 	DeviceID uint64    `json:"device_id"`
 	Inode    uint64    `json:"inode"`
+
+	// Reference: está en una carpeta de referencia. Puede ser Keeper, nunca víctima
+	Reference bool `json:"reference,omitempty"`
+
+	// Archive: ruta del archivo comprimido que lo contiene (Path es "archive!/miembro").
+	// Un miembro es virtual: se reporta, pero nunca se toca
+	Archive string `json:"archive,omitempty"`
+
+	// ChangeTime (ctime) solo se usa para validar la caché de hashes
+	ChangeTime time.Time `json:"-"`
 }
 
 // FileGroup representa un conjunto de archivos que comparten criterios.
 type FileGroup struct {
-	Count int64       `json:"count"`
-	Files []*FileInfo `json:"files"`
+	Count    int64       `json:"count"`
+	Files    []*FileInfo `json:"files"`
+	Verified bool        `json:"byte_verified"` // Contenido comparado byte a byte
 }
 
 // Add agrega un archivo al grupo
@@ -0,0 +1,55 @@
+package entities
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+)
+
+// Phase identifica la fase del pipeline en la que ocurrió un error.
+type Phase string
+
+const (
+	PhaseScan    Phase = "scan"
+	PhasePreHash Phase = "prehash"
+	PhaseHash    Phase = "hash"
+	PhaseVerify  Phase = "verify"
+)
+
+// ErrorKind clasifica el error para que un auditor pueda filtrarlo sin parsear mensajes.
+type ErrorKind string
+
+const (
+	ErrPermission ErrorKind = "permission"
+	ErrNotFound   ErrorKind = "not_found"
+	ErrIO         ErrorKind = "io"
+	ErrOther      ErrorKind = "other"
+)
+
+// PathError es un archivo o carpeta que no se pudo procesar.
+// Un archivo con error no aparece en los resultados: el escaneo está incompleto.
+type PathError struct {
+	Phase   Phase     `json:"phase"`
+	Path    string    `json:"path"`
+	Kind    ErrorKind `json:"kind"`
+	Message string    `json:"message"`
+}
+
+// NewPathError construye un PathError clasificando err.
+func NewPathError(phase Phase, path string, err error) PathError {
+	return PathError{Phase: phase, Path: path, Kind: ClassifyError(err), Message: err.Error()}
+}
+
+// ClassifyError asigna un ErrorKind a un error del sistema de archivos.
+func ClassifyError(err error) ErrorKind {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return ErrPermission
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrNotFound
+	case errors.Is(err, syscall.EIO):
+		return ErrIO
+	default:
+		return ErrOther
+	}
+}
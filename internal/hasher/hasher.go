@@ -1,12 +1,24 @@
 package hasher
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
 	"io"
-	"os"
+	"io/fs"
+	"sort"
+	"strings"
 	"sync"
-	"syscall"
+	"sync/atomic"
 
 	"github.com/cespare/xxhash/v2"
+	"github.com/zeebo/xxh3"
+	"golang.org/x/crypto/blake2b"
+
+	"github.com/soyunomas/dupedetector/internal/entities"
+	"github.com/soyunomas/dupedetector/internal/vfs"
 )
 
 // BlockSize optimiza la lectura del disco (32KB es un buen estándar)
@@ -15,6 +27,22 @@ const BlockSize = 32 * 1024
 // PreHashSize define cuánto leemos para la prueba rápida (4KB)
 const PreHashSize = 4 * 1024
 
+// Algoritmos disponibles. Los nombres son los que acepta el flag -hash.
+const (
+	AlgoXXHash  = "xxhash"   // xxHash64 (Default). Compatible con `xxhsum`
+	AlgoXXH3    = "xxh3-128" // XXH3 128 bits. Compatible con `xxhsum -H2`
+	AlgoSHA256  = "sha256"   // Criptográfico. Compatible con `sha256sum`
+	AlgoBLAKE2b = "blake2b"  // BLAKE2b-512 criptográfico. Compatible con `b2sum`
+)
+
+// constructors asocia cada algoritmo con la creación de su estado.
+var constructors = map[string]func() hash.Hash{
+	AlgoXXHash:  func() hash.Hash { return xxhash.New() },
+	AlgoXXH3:    func() hash.Hash { return &xxh3Digest128{xxh3.New()} },
+	AlgoSHA256:  sha256.New,
+	AlgoBLAKE2b: func() hash.Hash { h, _ := blake2b.New512(nil); return h },
+}
+
 // bufferPool solo para cargas pesadas (HashFile completo)
 var bufferPool = sync.Pool{
 	New: func() any {
@@ -23,79 +51,156 @@ var bufferPool = sync.Pool{
 	},
 }
 
-// hashPool para reutilizar el estado del digest
-var hashPool = sync.Pool{
-	New: func() any {
-		return xxhash.New()
-	},
-}
-
 type FileStats struct {
 	Size     int64
 	DeviceID uint64
 	Inode    uint64
 }
 
+// Hasher calcula el digest de archivos con un algoritmo concreto.
+// Los archivos se abren en fsys (vfs.OS = disco local).
+type Hasher interface {
+	// Name devuelve el nombre del algoritmo (ej: "sha256")
+	Name() string
+	// HashFile calcula el digest completo y devuelve los stats del descriptor abierto.
+	// Se interrumpe entre bloques si ctx se cancela. Si read no es nil, suma en él
+	// los bytes a medida que los lee (progreso dentro de archivos de varios GB).
+	HashFile(ctx context.Context, fsys fs.FS, path string, read *atomic.Int64) (entities.Digest, FileStats, error)
+	// HashFirstBlock calcula el digest de los primeros PreHashSize bytes
+	HashFirstBlock(fsys fs.FS, path string) (entities.Digest, error)
+}
+
+// New devuelve el Hasher para el algoritmo indicado. Cadena vacía = xxhash.
+func New(algo string) (Hasher, error) {
+	name := strings.ToLower(algo)
+	if name == "" {
+		name = AlgoXXHash
+	}
+	ctor, ok := constructors[name]
+	if !ok {
+		return nil, fmt.Errorf("algoritmo de hash desconocido: %s (disponibles: %s)", algo, strings.Join(Algorithms(), ", "))
+	}
+
+	return &streamHasher{
+		name: name,
+		// hashPool para reutilizar el estado del digest
+		hashPool: sync.Pool{New: func() any { return ctor() }},
+	}, nil
+}
+
+// Algorithms lista los algoritmos soportados en orden alfabético.
+func Algorithms() []string {
+	names := make([]string, 0, len(constructors))
+	for n := range constructors {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// streamHasher implementa Hasher sobre cualquier hash.Hash del stdlib o compatible.
+type streamHasher struct {
+	name     string
+	hashPool sync.Pool
+}
+
+func (s *streamHasher) Name() string { return s.name }
+
 // HashFile calcula el hash completo. Aquí SI vale la pena usar Pools.
-func HashFile(path string) (uint64, FileStats, error) {
-	file, err := os.Open(path)
+func (s *streamHasher) HashFile(ctx context.Context, fsys fs.FS, path string, read *atomic.Int64) (entities.Digest, FileStats, error) {
+	file, err := fsys.Open(path)
 	if err != nil {
-		return 0, FileStats{}, err
+		return "", FileStats{}, err
 	}
 	defer file.Close()
 
 	// Obtener stats del descriptor abierto (Rápido)
 	info, err := file.Stat()
 	if err != nil {
-		return 0, FileStats{}, err
+		return "", FileStats{}, err
 	}
 
-	stats := FileStats{Size: info.Size()}
-	if sys, ok := info.Sys().(*syscall.Stat_t); ok {
-		stats.DeviceID = uint64(sys.Dev)
-		stats.Inode = uint64(sys.Ino)
-	}
+	sys := vfs.Sys(fsys, info)
+	stats := FileStats{Size: info.Size(), DeviceID: sys.DeviceID, Inode: sys.Inode}
 
 	// Pooling
-	h := hashPool.Get().(*xxhash.Digest)
+	h := s.hashPool.Get().(hash.Hash)
 	h.Reset()
-	defer hashPool.Put(h)
+	defer s.hashPool.Put(h)
 
 	bufPtr := bufferPool.Get().(*[]byte)
 	buf := *bufPtr
 	defer bufferPool.Put(bufPtr)
 
-	if _, err := io.CopyBuffer(h, file, buf); err != nil {
-		return 0, stats, err
+	if _, err := io.CopyBuffer(h, ctxReader{ctx, file, read}, buf); err != nil {
+		return "", stats, err
 	}
 
-	return h.Sum64(), stats, nil
+	return sum(h), stats, nil
 }
 
 // HashFirstBlock optimizado para baja latencia.
 // NO usa sync.Pool de buffers para evitar contención en lecturas pequeñas.
-func HashFirstBlock(path string) (uint64, error) {
-	file, err := os.Open(path)
+func (s *streamHasher) HashFirstBlock(fsys fs.FS, path string) (entities.Digest, error) {
+	file, err := fsys.Open(path)
 	if err != nil {
-		return 0, err
+		return "", err
 	}
 	defer file.Close()
 
-	h := hashPool.Get().(*xxhash.Digest)
+	h := s.hashPool.Get().(hash.Hash)
 	h.Reset()
-	defer hashPool.Put(h)
+	defer s.hashPool.Put(h)
 
 	// Alloc simple de 4KB. Es barato y evita locking del Pool global.
 	// Usamos ReadFull para asegurar consistencia.
 	buf := make([]byte, PreHashSize)
 	n, err := io.ReadFull(file, buf)
-	
+
 	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
-		return 0, err
+		return "", err
 	}
 
 	// Hash de lo que se haya podido leer
 	_, _ = h.Write(buf[:n])
 
-	return h.Sum64(), nil
+	return sum(h), nil
+}
+
+// sum codifica el digest en hexadecimal, el mismo formato que imprimen las herramientas *sum.
+func sum(h hash.Hash) entities.Digest {
+	var arr [blake2b.Size]byte
+	return entities.Digest(hex.EncodeToString(h.Sum(arr[:0])))
+}
+
+// xxh3Digest128 adapta xxh3.Hasher (cuyo Sum es de 64 bits) para exponer los 128 bits.
+type xxh3Digest128 struct {
+	*xxh3.Hasher
+}
+
+func (d *xxh3Digest128) Size() int { return 16 }
+
+func (d *xxh3Digest128) Sum(b []byte) []byte {
+	sum := d.Sum128().Bytes()
+	return append(b, sum[:]...)
+}
+
+// ctxReader corta la lectura en cuanto se cancela el contexto (un archivo de
+// varios GB no debe retrasar un Ctrl-C) y, opcionalmente, cuenta los bytes leídos.
+// Se comprueba una vez por bloque.
+type ctxReader struct {
+	ctx     context.Context
+	r       io.Reader
+	counter *atomic.Int64
+}
+
+func (c ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := c.r.Read(p)
+	if c.counter != nil {
+		c.counter.Add(int64(n))
+	}
+	return n, err
 }
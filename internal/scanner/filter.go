@@ -0,0 +1,152 @@
+package scanner
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// DefaultExcludes se ignoran siempre, salvo que se pida lo contrario (-no-default-excludes).
+var DefaultExcludes = []string{".git", "node_modules", ".DS_Store"}
+
+// pathFilter decide qué entradas se excluyen o incluyen durante el recorrido.
+//
+// Reglas de los globs (sintaxis doublestar, separador "/"):
+//   - Sin "/": se comparan con el nombre base, a cualquier profundidad (".git", "*.tmp").
+//   - Con "/": se comparan con la ruta relativa a la raíz ("**/*.tmp", "cache/*/thumbs").
+//   - Terminados en "/": solo afectan a directorios ("build/").
+//
+// Las regex se comparan siempre con la ruta relativa completa.
+type pathFilter struct {
+	names    map[string]struct{} // Nombres literales: búsqueda O(1)
+	excludes []string
+	includes []string
+	exRegex  []*regexp.Regexp
+	inRegex  []*regexp.Regexp
+	exts     map[string]struct{} // Extensiones permitidas (".mp4"), en minúsculas
+	denyExts map[string]struct{}
+}
+
+func newPathFilter(cfg Config) *pathFilter {
+	f := &pathFilter{
+		names:    make(map[string]struct{}),
+		includes: cfg.Includes,
+		exRegex:  cfg.ExcludeRegex,
+		inRegex:  cfg.IncludeRegex,
+		exts:     extSet(cfg.Extensions),
+		denyExts: extSet(cfg.ExcludeExts),
+	}
+	for _, e := range cfg.Excludes {
+		if isLiteral(e) {
+			f.names[e] = struct{}{}
+		} else {
+			f.excludes = append(f.excludes, e)
+		}
+	}
+	return f
+}
+
+// excluded indica si la entrada (archivo o directorio) debe ignorarse.
+func (f *pathFilter) excluded(rel, name string, isDir bool) bool {
+	if _, ok := f.names[name]; ok {
+		return true
+	}
+	for _, p := range f.excludes {
+		if matchGlob(p, rel, name, isDir) {
+			return true
+		}
+	}
+	for _, re := range f.exRegex {
+		if re.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// included indica si un archivo pasa los filtros -include. Sin includes, todo pasa.
+// Los directorios no se filtran por include: podarlos impediría que "**/*.jpg" encuentre nada.
+func (f *pathFilter) included(rel, name string) bool {
+	if len(f.includes) == 0 && len(f.inRegex) == 0 {
+		return true
+	}
+	for _, p := range f.includes {
+		if matchGlob(p, rel, name, false) {
+			return true
+		}
+	}
+	for _, re := range f.inRegex {
+		if re.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedExt aplica las listas de extensiones (sin distinguir mayúsculas).
+func (f *pathFilter) allowedExt(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	if _, deny := f.denyExts[ext]; deny {
+		return false
+	}
+	if len(f.exts) == 0 {
+		return true
+	}
+	_, ok := f.exts[ext]
+	return ok
+}
+
+// extSet normaliza "MP4", "mp4" o ".mp4" a ".mp4".
+func extSet(exts []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(exts))
+	for _, e := range exts {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e == "" {
+			continue
+		}
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		set[e] = struct{}{}
+	}
+	return set
+}
+
+// matchGlob aplica las reglas de globs descritas en pathFilter.
+func matchGlob(pattern, rel, name string, isDir bool) bool {
+	if strings.HasSuffix(pattern, "/") {
+		if !isDir {
+			return false
+		}
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	if !strings.Contains(pattern, "/") {
+		return doublestar.MatchUnvalidated(pattern, name)
+	}
+	return doublestar.MatchUnvalidated(pattern, rel)
+}
+
+// ValidatePattern comprueba la sintaxis de un glob antes de escanear.
+func ValidatePattern(pattern string) error {
+	if !doublestar.ValidatePattern(strings.TrimSuffix(pattern, "/")) {
+		return fmt.Errorf("patrón inválido: %s", pattern)
+	}
+	return nil
+}
+
+// isLiteral indica si el patrón es un nombre sin comodines ni rutas.
+func isLiteral(pattern string) bool {
+	return !strings.ContainsAny(pattern, "*?[{\\/")
+}
+
+// relPath devuelve la ruta relativa a la raíz con separador "/".
+func relPath(root, p string) string {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return filepath.ToSlash(p)
+	}
+	return filepath.ToSlash(rel)
+}
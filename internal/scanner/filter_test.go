@@ -0,0 +1,108 @@
+package scanner
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	root := tree(t, map[string]string{
+		"a.jpg":                 "x",
+		"a.tmp":                 "x",
+		"build":                 "x", // Archivo, no directorio
+		"fotos/b.jpg":           "x",
+		"fotos/b.png":           "x",
+		"fotos/raw/c.jpg":       "x",
+		"fotos/.git/d.jpg":      "x",
+		"build2/build/e.jpg":    "x",
+		"cache/x/thumbs/f.jpg":  "x",
+		"otro/cache/x/thumbs/g": "x",
+	})
+	re := regexp.MustCompile
+
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{"sin filtros", Config{}, []string{
+			"a.jpg", "a.tmp", "build", "build2/build/e.jpg", "cache/x/thumbs/f.jpg",
+			"fotos/.git/d.jpg", "fotos/b.jpg", "fotos/b.png", "fotos/raw/c.jpg", "otro/cache/x/thumbs/g",
+		}},
+		{"nombre literal a cualquier profundidad", Config{Excludes: []string{".git", "raw"}}, []string{
+			"a.jpg", "a.tmp", "build", "build2/build/e.jpg", "cache/x/thumbs/f.jpg",
+			"fotos/b.jpg", "fotos/b.png", "otro/cache/x/thumbs/g",
+		}},
+		{"glob sin / contra el nombre", Config{Excludes: []string{"*.tmp", "*.png"}}, []string{
+			"a.jpg", "build", "build2/build/e.jpg", "cache/x/thumbs/f.jpg",
+			"fotos/.git/d.jpg", "fotos/b.jpg", "fotos/raw/c.jpg", "otro/cache/x/thumbs/g",
+		}},
+		{"glob con / contra la ruta relativa", Config{Excludes: []string{"cache/*/thumbs"}}, []string{
+			"a.jpg", "a.tmp", "build", "build2/build/e.jpg",
+			"fotos/.git/d.jpg", "fotos/b.jpg", "fotos/b.png", "fotos/raw/c.jpg", "otro/cache/x/thumbs/g",
+		}},
+		{"glob terminado en / solo afecta a directorios", Config{Excludes: []string{"build*/"}}, []string{
+			"a.jpg", "a.tmp", "build", "cache/x/thumbs/f.jpg",
+			"fotos/.git/d.jpg", "fotos/b.jpg", "fotos/b.png", "fotos/raw/c.jpg", "otro/cache/x/thumbs/g",
+		}},
+		{"include no poda directorios", Config{Includes: []string{"*.jpg"}}, []string{
+			"a.jpg", "build2/build/e.jpg", "cache/x/thumbs/f.jpg",
+			"fotos/.git/d.jpg", "fotos/b.jpg", "fotos/raw/c.jpg",
+		}},
+		{"include con ruta", Config{Includes: []string{"fotos/**/*.jpg"}}, []string{
+			"fotos/.git/d.jpg", "fotos/b.jpg", "fotos/raw/c.jpg",
+		}},
+		{"include glob o regex", Config{Includes: []string{"*.png"}, IncludeRegex: []*regexp.Regexp{re(`^fotos/raw/`)}}, []string{
+			"fotos/b.png", "fotos/raw/c.jpg",
+		}},
+		{"exclude gana a include", Config{Includes: []string{"*.jpg"}, Excludes: []string{"raw", ".git", "a.jpg"}}, []string{
+			"build2/build/e.jpg", "cache/x/thumbs/f.jpg", "fotos/b.jpg",
+		}},
+		{"exclude regex gana a include", Config{IncludeRegex: []*regexp.Regexp{re(`\.jpg$`)}, ExcludeRegex: []*regexp.Regexp{re(`^fotos/`), re(`thumbs`)}}, []string{
+			"a.jpg", "build2/build/e.jpg",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scanned(t, tt.cfg, root); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("escaneados:\n  %v\nquería:\n  %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	for _, p := range []string{"*.jpg", "**/cache/", "fotos/{a,b}/*"} {
+		if err := ValidatePattern(p); err != nil {
+			t.Errorf("ValidatePattern(%q) = %v", p, err)
+		}
+	}
+	if err := ValidatePattern("fotos/[a"); err == nil {
+		t.Error("ValidatePattern aceptó un corchete sin cerrar")
+	}
+}
+
+// scanned devuelve, ordenadas, las rutas relativas a root que Scan encontró.
+func scanned(t *testing.T, cfg Config, root string) []string {
+	t.Helper()
+	bySize, err := New(cfg).Scan(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, g := range bySize {
+		for _, f := range g.Files {
+			rel, err := filepath.Rel(root, f.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, filepath.ToSlash(rel))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,121 @@
+package scanner
+
+import (
+	"bufio"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// DefaultIgnoreFile es el archivo de exclusiones propio de dupedetector.
+const DefaultIgnoreFile = ".dupeignore"
+
+// ignoreRule es una línea de un archivo estilo .gitignore.
+type ignoreRule struct {
+	pattern  string
+	negate   bool // "!patrón": vuelve a incluir
+	dirOnly  bool // "patrón/": solo directorios
+	anchored bool // Contiene "/": relativo al directorio del archivo
+}
+
+// ignoreFile son las reglas de un archivo, válidas para su directorio y los de debajo.
+type ignoreFile struct {
+	base  string
+	rules []ignoreRule
+}
+
+// loadIgnoreFile lee dir/name. Devuelve nil si no existe o no tiene reglas.
+func loadIgnoreFile(fsys fs.FS, dir, name string) *ignoreFile {
+	f, err := fsys.Open(path.Join(dir, name))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if rule, ok := parseIgnoreLine(sc.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	return &ignoreFile{base: dir, rules: rules}
+}
+
+// parseIgnoreLine interpreta una línea con la semántica de gitignore.
+// Las líneas vacías, los comentarios y los patrones inválidos se descartan.
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+
+	// Los espacios finales se ignoran salvo que estén escapados ("\ ")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	// Una "/" al principio o en medio ancla el patrón al directorio del archivo
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	if line == "" || !doublestar.ValidatePattern(line) {
+		return ignoreRule{}, false
+	}
+	rule.pattern = line
+	return rule, true
+}
+
+// match indica si alguna regla del archivo afecta a path y, en ese caso,
+// si la última que coincide lo excluye (gana la última, como en git).
+func (f *ignoreFile) match(path string, isDir bool) (matched, ignored bool) {
+	rel := relPath(f.base, path)
+	name := filepath.Base(path)
+
+	for _, r := range f.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		target := name
+		if r.anchored {
+			target = rel
+		}
+		if doublestar.MatchUnvalidated(r.pattern, target) {
+			matched, ignored = true, !r.negate
+		}
+	}
+	return matched, ignored
+}
+
+// isIgnored evalúa la pila de archivos de ignore, del más externo al más profundo.
+// Un archivo más profundo tiene prioridad sobre los de sus directorios padre.
+func isIgnored(stack []*ignoreFile, path string, isDir bool) bool {
+	ignored := false
+	for _, f := range stack {
+		if m, ign := f.match(path, isDir); m {
+			ignored = ign
+		}
+	}
+	return ignored
+}
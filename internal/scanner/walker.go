@@ -1,105 +1,551 @@
 package scanner
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/fs"
-//	"os"
+	"path"
 	"path/filepath"
-	"syscall"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/soyunomas/dupedetector/internal/archive"
 	"github.com/soyunomas/dupedetector/internal/entities"
+	"github.com/soyunomas/dupedetector/internal/vfs"
 )
 
 // Config define las reglas para el escaneo.
 type Config struct {
-	MinSize   int64    // Tamaño mínimo en bytes para considerar
-	Excludes  []string // Lista de carpetas a ignorar
+	MinSize      int64            // Tamaño mínimo en bytes para considerar
+	MaxSize      int64            // Tamaño máximo en bytes (0 = sin límite)
+	NewerThan    time.Time        // Solo archivos modificados después (cero = sin límite)
+	OlderThan    time.Time        // Solo archivos modificados antes (cero = sin límite)
+	Extensions   []string         // Extensiones permitidas, sin distinguir mayúsculas (vacío = todas)
+	ExcludeExts  []string         // Extensiones a ignorar
+	Excludes     []string         // Nombres o globs a ignorar (archivos y carpetas)
+	Includes     []string         // Globs de archivos a considerar (vacío = todos)
+	ExcludeRegex []*regexp.Regexp // Regex sobre la ruta relativa a ignorar
+	IncludeRegex []*regexp.Regexp // Regex sobre la ruta relativa a considerar
+	IgnoreFiles  []string         // Archivos estilo .gitignore a respetar (".dupeignore", ".gitignore")
+	References   []string         // Carpetas de referencia (solo lectura): se escanean, nunca son víctimas
+
+	FollowSymlinks bool // Seguir symlinks (con detección de bucles); por defecto se ignoran
+	OneFileSystem  bool // No cruzar puntos de montaje
+	Archives       bool // Listar también los miembros de .zip/.tar(.gz/.bz2) como archivos virtuales
+	RecordSkipped  bool // Guardar las entradas saltadas (ver Skipped); compare lo usa en B
+
+	// FS a recorrer (nil = disco local). Con otro FS, raíces y rutas son las de ese FS ("." o "fotos/2024")
+	FS fs.FS
+
+	OnRoot func(root string)          // Opcional: al empezar cada raíz
+	OnFile func(f *entities.FileInfo) // Opcional: por cada archivo aceptado
 }
 
 // FileScanner encapsula la lógica de recorrido del sistema de archivos.
 type FileScanner struct {
-	cfg        Config
-	excludeMap map[string]struct{} // Optimización O(1)
+	cfg      Config
+	fsys     fs.FS
+	filter   *pathFilter
+	refRoots map[string]struct{}      // Referencias (rutas resueltas)
+	refDirs  map[string]struct{}      // Directorios visitados que están bajo una referencia
+	ignores  map[string][]*ignoreFile // Reglas activas en cada directorio visitado
+	visited  map[dirID]struct{}       // Directorios físicos ya recorridos (-follow-symlinks)
+	partial  map[string]struct{}      // Directorios con alguna entrada que no se agregó
+	dirs     []string                 // Directorios recorridos (incluidos los vacíos)
+	skipped  []string                 // Entradas que se saltaron a propósito (no errores)
+	errs     []entities.PathError     // Rutas que no se pudieron leer
+}
+
+// dirID identifica un directorio físico.
+type dirID struct {
+	dev, inode uint64
 }
 
 // New crea una nueva instancia del escáner con configuración.
 func New(cfg Config) *FileScanner {
-	// Pre-procesamos excludes a un mapa para búsquedas instantáneas
-	exMap := make(map[string]struct{}, len(cfg.Excludes))
-	for _, e := range cfg.Excludes {
-		exMap[e] = struct{}{}
+	s := &FileScanner{
+		cfg:      cfg,
+		fsys:     cfg.FS,
+		filter:   newPathFilter(cfg),
+		refRoots: make(map[string]struct{}, len(cfg.References)),
+		refDirs:  make(map[string]struct{}),
+		ignores:  make(map[string][]*ignoreFile),
+		visited:  make(map[dirID]struct{}),
+		partial:  make(map[string]struct{}),
 	}
-
-	return &FileScanner{
-		cfg:        cfg,
-		excludeMap: exMap,
+	if s.fsys == nil {
+		s.fsys = vfs.OS
+	}
+	for _, r := range cfg.References {
+		s.refRoots[s.resolve(r)] = struct{}{}
 	}
+	return s
 }
 
-// Scan recorre rootDir y devuelve un mapa inicial agrupado por tamaño.
+// Scan recorre las raíces (y las referencias) y devuelve un único mapa agrupado por tamaño.
+// Las raíces contenidas dentro de otra se descartan para no contar archivos dos veces.
+// Si ctx se cancela, devuelve lo encontrado hasta ese momento junto con ctx.Err().
 // Map: [Tamaño] -> [Grupo de Archivos]
-func (s *FileScanner) Scan(rootDir string) (map[int64]*entities.FileGroup, error) {
+func (s *FileScanner) Scan(ctx context.Context, roots ...string) (map[int64]*entities.FileGroup, error) {
 	// Inicializamos el mapa. Usamos punteros para evitar copias de memoria innecesarias.
 	filesBySize := make(map[int64]*entities.FileGroup)
 
-	fmt.Printf("🔍 Iniciando escaneo en: %s\n", rootDir)
+	if err := s.checkRoots(roots); err != nil {
+		return filesBySize, err
+	}
+	all := append(append([]string{}, roots...), s.cfg.References...)
+	for _, root := range normalizeRoots(all, s.resolve) {
+		if err := s.walk(ctx, root, filesBySize); err != nil {
+			return filesBySize, err
+		}
+	}
+	return filesBySize, nil
+}
+
+// checkRoots rechaza una raíz que esté dentro de una referencia (o sea la misma):
+// normalizeRoots la descartaría por contenida y todos sus archivos pasarían a ser
+// de solo lectura, sin que ningún duplicado pudiera proponerse como víctima.
+// Una referencia dentro de una raíz sí es válida: solo esa parte es de solo lectura.
+func (s *FileScanner) checkRoots(roots []string) error {
+	for _, root := range roots {
+		resolved := s.resolve(root)
+		for _, ref := range s.cfg.References {
+			if isWithin(resolved, s.resolve(ref)) {
+				return fmt.Errorf("la raíz %s está dentro de la referencia %s: sus archivos nunca serían víctimas", root, ref)
+			}
+		}
+	}
+	return nil
+}
 
-	err := filepath.WalkDir(rootDir, func(path string, d fs.DirEntry, err error) error {
-		// 1. Manejo de errores de acceso (permisos, etc)
+// walk recorre una raíz y agrega sus archivos a filesBySize.
+func (s *FileScanner) walk(ctx context.Context, rootDir string, filesBySize map[int64]*entities.FileGroup) error {
+	if s.cfg.OnRoot != nil {
+		s.cfg.OnRoot(rootDir)
+	}
+
+	w := &walkState{ctx: ctx, root: rootDir, filesBySize: filesBySize}
+	if s.cfg.OneFileSystem {
+		info, err := fs.Stat(s.fsys, rootDir)
+		if err != nil {
+			return err
+		}
+		w.rootDev = vfs.Sys(s.fsys, info).DeviceID
+	}
+
+	// Una raíz que es symlink a directorio se recorre siempre: la pidió el usuario
+	// (fs.WalkDir resuelve la raíz con Stat)
+	return s.walkFrom(w, rootDir)
+}
+
+// walkState es el estado de recorrido de una raíz.
+type walkState struct {
+	ctx         context.Context
+	root        string
+	rootDev     uint64 // Dispositivo de la raíz (-one-file-system)
+	filesBySize map[int64]*entities.FileGroup
+}
+
+// walkFrom recorre start (la raíz o un symlink a directorio, que WalkDir sigue
+// por ser el punto de partida).
+func (s *FileScanner) walkFrom(w *walkState, start string) error {
+	return fs.WalkDir(s.fsys, start, func(path string, d fs.DirEntry, err error) error {
+		// 0. Cancelación (Ctrl-C): corta el recorrido entero
+		if ctxErr := w.ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		// 1. Manejo de errores de acceso (permisos, etc): se registran y se sigue
 		if err != nil {
-			return nil 
+			s.recordError(path, err)
+			s.markPartial(path) // Si es un directorio ilegible, su contenido es desconocido
+			return nil
+		}
+		if path == start {
+			path = filepath.Clean(path) // "fotos/" -> "fotos": mismas claves que sus hijos
 		}
 
-		// 2. Si es directorio, verificamos si debemos ignorarlo (Optimizado)
-		if d.IsDir() {
-			if _, ok := s.excludeMap[d.Name()]; ok {
-				return filepath.SkipDir
+		// 2. Tipo de entrada: symlinks y archivos especiales
+		// Sin -follow-symlinks se ignoran; FIFOs, sockets y dispositivos siempre.
+		// Todo lo que se salte deja a su carpeta incompleta (ver Incomplete).
+		isDir := d.IsDir()
+		var info fs.FileInfo
+		if d.Type()&fs.ModeSymlink != 0 {
+			// Un symlink, aunque se siga, no se mueve con su carpeta como lo hace su contenido
+			s.markPartial(filepath.Dir(path))
+			if !s.cfg.FollowSymlinks {
+				s.omit(path)
+				return nil
+			}
+			if info, err = fs.Stat(s.fsys, path); err != nil {
+				if errors.Is(err, fs.ErrNotExist) { // Un symlink roto no es un error de lectura
+					s.omit(path)
+				} else {
+					s.recordError(path, err)
+				}
+				return nil
 			}
+			isDir = info.IsDir()
+			if !isDir && !info.Mode().IsRegular() {
+				s.omit(path)
+				return nil
+			}
+		} else if !isDir && !d.Type().IsRegular() {
+			s.omit(path)
 			return nil
 		}
 
-		// 3. Obtener información del archivo (Stat)
-		info, err := d.Info()
-		if err != nil {
+		// 3. Filtros de exclusión (la raíz nunca se excluye)
+		rel := relPath(w.root, path)
+		if rel != "." && s.filter.excluded(rel, d.Name(), isDir) {
+			s.omit(path)
+			return skip(d)
+		}
+		if rel != "." && isIgnored(s.ignores[filepath.Dir(path)], path, isDir) {
+			s.omit(path)
+			return skip(d)
+		}
+
+		if isDir {
+			// Un symlink a directorio se recorre aparte, por su propia ruta
+			if info != nil {
+				return s.walkFrom(w, path)
+			}
+			if !s.enterDir(w, path, d) {
+				s.omit(path)
+				return fs.SkipDir
+			}
+			s.dirs = append(s.dirs, path)
+			s.loadIgnores(path)
+			s.markReference(path)
+			return nil
+		}
+		// Los miembros se filtran por su cuenta: "-ext jpg" debe encontrar las fotos de un zip
+		if s.cfg.Archives && archive.IsArchive(d.Name()) {
+			s.addMembers(w, path, rel)
+		}
+		if !s.filter.included(rel, d.Name()) || !s.filter.allowedExt(d.Name()) {
+			s.omit(path)
 			return nil
 		}
 
-		// 4. Filtro de Tamaño
+		// 4. Obtener información del archivo (Stat)
+		if info == nil {
+			if info, err = d.Info(); err != nil {
+				s.recordError(path, err)
+				s.markPartial(filepath.Dir(path))
+				return nil
+			}
+		}
+
+		// 5. Filtros de Tamaño y Fecha
 		size := info.Size()
-		if size < s.cfg.MinSize {
+		modTime := info.ModTime()
+		if !s.accepted(size, modTime) {
+			s.omit(path)
 			return nil
 		}
 
-		// 5. Construcción de la Entidad
-		// Extraemos Inode/Device de forma específica según el OS (syscall)
-		devID, inode := getSysInfo(info)
+		// 6. Construcción de la Entidad
+		// Inode/Device según el FS (en disco, el Stat_t del OS)
+		sys := vfs.Sys(s.fsys, info)
+		if s.cfg.OneFileSystem && sys.DeviceID != w.rootDev {
+			s.omit(path)
+			return nil
+		}
 
 		fileEntity := &entities.FileInfo{
-			Path:     path,
-			Size:     size,
-			ModTime:  info.ModTime(),
-			DeviceID: devID,
-			Inode:    inode,
+			Path:       path,
+			Size:       size,
+			ModTime:    modTime,
+			DeviceID:   sys.DeviceID,
+			Inode:      sys.Inode,
+			ChangeTime: sys.ChangeTime,
+			Reference:  s.isReference(filepath.Dir(path)),
 			// Hash se calculará en la siguiente fase (Fase 2)
 		}
 
-		// 6. Agrupar
-		if _, exists := filesBySize[size]; !exists {
-			filesBySize[size] = &entities.FileGroup{}
+		// 7. Agrupar
+		s.add(w, fileEntity)
+		return nil
+	})
+}
+
+// accepted aplica los filtros de tamaño y fecha.
+func (s *FileScanner) accepted(size int64, modTime time.Time) bool {
+	if size < s.cfg.MinSize || (s.cfg.MaxSize > 0 && size > s.cfg.MaxSize) {
+		return false
+	}
+	if !s.cfg.NewerThan.IsZero() && !modTime.After(s.cfg.NewerThan) {
+		return false
+	}
+	if !s.cfg.OlderThan.IsZero() && !modTime.Before(s.cfg.OlderThan) {
+		return false
+	}
+	return true
+}
+
+// add agrega un archivo aceptado a su grupo de tamaño.
+func (s *FileScanner) add(w *walkState, f *entities.FileInfo) {
+	if _, exists := w.filesBySize[f.Size]; !exists {
+		w.filesBySize[f.Size] = &entities.FileGroup{}
+	}
+	w.filesBySize[f.Size].Add(f)
+	if s.cfg.OnFile != nil {
+		s.cfg.OnFile(f)
+	}
+}
+
+// addMembers agrega los miembros regulares de un archivo comprimido como archivos
+// virtuales ("backup.zip!/src/main.go"). Pasan los mismos filtros que un archivo
+// suelto, con las carpetas del miembro como si fueran directorios bajo el comprimido.
+// Un comprimido ilegible o corrupto se registra como error y se sigue.
+func (s *FileScanner) addMembers(w *walkState, file, rel string) {
+	members, err := archive.Members(s.fsys, file)
+	if err != nil {
+		s.recordError(file, err)
+	}
+	ref := s.isReference(filepath.Dir(file))
+
+	for _, m := range members {
+		memberRel := archive.Join(rel, m.Name)
+		name := path.Base(m.Name)
+		if s.memberExcluded(rel, m.Name) || !s.filter.included(memberRel, name) || !s.filter.allowedExt(name) {
+			continue
 		}
-		filesBySize[size].Add(fileEntity)
+		if !s.accepted(m.Size, m.ModTime) {
+			continue
+		}
+		s.add(w, &entities.FileInfo{
+			Path:      archive.Join(file, m.Name),
+			Size:      m.Size,
+			ModTime:   m.ModTime,
+			Reference: ref,
+			Archive:   file,
+		})
+	}
+}
 
-		return nil
+// memberExcluded aplica -exclude a cada carpeta del miembro y al propio miembro.
+func (s *FileScanner) memberExcluded(rel, member string) bool {
+	parts := strings.Split(member, "/")
+	for i, name := range parts {
+		if name == "" {
+			continue
+		}
+		isDir := i < len(parts)-1
+		if s.filter.excluded(archive.Join(rel, strings.Join(parts[:i+1], "/")), name, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// enterDir decide si se recorre un directorio:
+// con -one-file-system no se cruzan puntos de montaje, y con -follow-symlinks
+// cada directorio físico se visita una sola vez (evita bucles de symlinks).
+func (s *FileScanner) enterDir(w *walkState, path string, d fs.DirEntry) bool {
+	if !s.cfg.OneFileSystem && !s.cfg.FollowSymlinks {
+		return true
+	}
+	info, err := d.Info()
+	if err != nil {
+		s.recordError(path, err)
+		return false
+	}
+	sys := vfs.Sys(s.fsys, info)
+	if s.cfg.OneFileSystem && sys.DeviceID != w.rootDev {
+		return false
+	}
+	if s.cfg.FollowSymlinks && sys.Inode != 0 {
+		id := dirID{sys.DeviceID, sys.Inode}
+		if _, seen := s.visited[id]; seen {
+			return false
+		}
+		s.visited[id] = struct{}{}
+	}
+	return true
+}
+
+// recordError anota una ruta que no se pudo leer durante el recorrido.
+func (s *FileScanner) recordError(path string, err error) {
+	s.errs = append(s.errs, entities.NewPathError(entities.PhaseScan, path, err))
+}
+
+// omit anota una entrada que se saltó a propósito (filtro, exclusión, symlink,
+// archivo especial...) y deja a su carpeta incompleta.
+func (s *FileScanner) omit(path string) {
+	if s.cfg.RecordSkipped {
+		s.skipped = append(s.skipped, path)
+	}
+	s.markPartial(filepath.Dir(path))
+}
+
+// markPartial anota que dir (y por tanto sus ancestros) tiene algo que no se agregó.
+func (s *FileScanner) markPartial(dir string) {
+	for {
+		if _, ok := s.partial[dir]; ok {
+			return
+		}
+		s.partial[dir] = struct{}{}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return
+		}
+		dir = parent
+	}
+}
+
+// Incomplete indica si bajo dir quedó algo sin agregar: filtrado, excluido,
+// ignorado, ilegible, symlink o archivo especial. Una carpeta así no es una
+// copia completa de nada, aunque sus archivos agregados lo sean.
+func (s *FileScanner) Incomplete(dir string) bool {
+	_, ok := s.partial[dir]
+	return ok
+}
+
+// Dirs devuelve los directorios recorridos durante Scan, también los que no
+// tienen ningún archivo agregado (para comparar la estructura de carpetas).
+func (s *FileScanner) Dirs() []string {
+	return s.dirs
+}
+
+// Skipped devuelve (con Config.RecordSkipped) las entradas que Scan saltó a propósito: filtradas, excluidas,
+// ignoradas, symlinks o archivos especiales. Una carpeta excluida cuenta como una
+// sola entrada. Los miembros de comprimidos filtrados no se cuentan.
+func (s *FileScanner) Skipped() []string {
+	return s.skipped
+}
+
+// Errors devuelve las rutas que no se pudieron leer durante Scan.
+func (s *FileScanner) Errors() []entities.PathError {
+	return s.errs
+}
+
+// skip devuelve SkipDir para directorios y nil para archivos.
+func skip(d fs.DirEntry) error {
+	if d.IsDir() {
+		return fs.SkipDir
+	}
+	return nil
+}
+
+// loadIgnores hereda las reglas del padre y añade las de los archivos de ignore de dir.
+func (s *FileScanner) loadIgnores(dir string) {
+	if len(s.cfg.IgnoreFiles) == 0 {
+		return
+	}
+	parent := s.ignores[filepath.Dir(dir)]
+	stack := parent
+	for _, name := range s.cfg.IgnoreFiles {
+		if f := loadIgnoreFile(s.fsys, dir, name); f != nil {
+			// Copia: los hermanos comparten el slice del padre
+			if len(stack) == len(parent) {
+				stack = append(make([]*ignoreFile, 0, len(parent)+1), parent...)
+			}
+			stack = append(stack, f)
+		}
+	}
+	if len(stack) > 0 {
+		s.ignores[dir] = stack
+	}
+}
+
+// markReference registra dir si está bajo una referencia.
+// Se hereda del padre, así que solo resolvemos symlinks de los directorios candidatos.
+func (s *FileScanner) markReference(dir string) {
+	if len(s.refRoots) == 0 {
+		return
+	}
+	if s.isReference(filepath.Dir(dir)) {
+		s.refDirs[dir] = struct{}{}
+		return
+	}
+	if _, ok := s.refRoots[s.resolve(dir)]; ok {
+		s.refDirs[dir] = struct{}{}
+	}
+}
+
+// isReference indica si dir (ya visitado) está bajo una referencia.
+func (s *FileScanner) isReference(dir string) bool {
+	_, ok := s.refDirs[dir]
+	return ok
+}
+
+// normalizeRoots elimina raíces repetidas o anidadas dentro de otra raíz.
+// La comparación se hace sobre rutas resueltas (en disco: absolutas y sin symlinks),
+// pero se conserva la forma original de cada raíz para el recorrido.
+func normalizeRoots(roots []string, resolve func(string) string) []string {
+	type root struct {
+		idx      int
+		orig     string
+		resolved string
+	}
+
+	candidates := make([]root, 0, len(roots))
+	for i, r := range roots {
+		candidates = append(candidates, root{idx: i, orig: r, resolved: resolve(r)})
+	}
+
+	// Las más cortas primero: un padre siempre se evalúa antes que sus hijos
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return len(candidates[i].resolved) < len(candidates[j].resolved)
 	})
 
-	return filesBySize, err
+	var kept []root
+	for _, c := range candidates {
+		nested := false
+		for _, k := range kept {
+			if isWithin(c.resolved, k.resolved) {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			kept = append(kept, c)
+		}
+	}
+
+	// Restauramos el orden en que el usuario las pasó
+	sort.Slice(kept, func(i, j int) bool { return kept[i].idx < kept[j].idx })
+	out := make([]string, 0, len(kept))
+	for _, k := range kept {
+		out = append(out, k.orig)
+	}
+	return out
+}
+
+// resolve normaliza una ruta del FS para compararla con otras.
+// Fuera del disco no hay rutas absolutas ni symlinks que resolver.
+func (s *FileScanner) resolve(p string) string {
+	if s.fsys != vfs.OS {
+		return path.Clean(p)
+	}
+	return resolvePath(p)
+}
+
+// resolvePath devuelve la ruta absoluta y sin symlinks (o lo mejor posible).
+func resolvePath(p string) string {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return filepath.Clean(p)
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real
+	}
+	return abs
 }
 
-// getSysInfo extrae DeviceID e Inode de forma "segura".
-func getSysInfo(info fs.FileInfo) (uint64, uint64) {
-	stat, ok := info.Sys().(*syscall.Stat_t)
-	if !ok {
-		return 0, 0 
+// isWithin indica si path es igual a parent o está dentro de él.
+func isWithin(path, parent string) bool {
+	if path == parent || parent == "." {
+		return true
+	}
+	if !strings.HasSuffix(parent, string(filepath.Separator)) {
+		parent += string(filepath.Separator)
 	}
-	return uint64(stat.Dev), uint64(stat.Ino)
+	return strings.HasPrefix(path, parent)
 }
@@ -0,0 +1,61 @@
+//go:build linux
+
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+// FIFOs y dispositivos nunca se agrupan: abrirlos bloquearía o leería sin fin.
+func TestScanSkipsSpecialFiles(t *testing.T) {
+	root := tree(t, map[string]string{"a.txt": "x"})
+	if err := syscall.Mkfifo(filepath.Join(root, "fifo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Crear dispositivos requiere privilegios: sin ellos se prueba solo el FIFO
+	if err := syscall.Mknod(filepath.Join(root, "null"), syscall.S_IFCHR|0644, 1<<8|3); err != nil {
+		t.Logf("sin dispositivo de caracteres: %v", err)
+	}
+	if err := os.Symlink("fifo", filepath.Join(root, "enlace")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, follow := range []bool{false, true} {
+		got := scanned(t, Config{FollowSymlinks: follow}, root)
+		if want := []string{"a.txt"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("FollowSymlinks=%v: escaneados %v, quería %v", follow, got, want)
+		}
+	}
+}
+
+// -one-file-system no entra en un sistema de archivos montado dentro de la raíz.
+func TestScanOneFileSystem(t *testing.T) {
+	root := tree(t, map[string]string{"a.txt": "x", "montaje/.keep": ""})
+	mnt := filepath.Join(root, "montaje")
+	if err := syscall.Mount("tmpfs", mnt, "tmpfs", 0, ""); err != nil {
+		t.Skipf("no se puede montar un tmpfs: %v", err)
+	}
+	t.Cleanup(func() { syscall.Unmount(mnt, 0) })
+	if err := os.WriteFile(filepath.Join(mnt, "b.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := scanned(t, Config{}, root), []string{"a.txt", "montaje/b.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sin -one-file-system: %v, quería %v", got, want)
+	}
+	if got, want := scanned(t, Config{OneFileSystem: true}, root), []string{"a.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("con -one-file-system: %v, quería %v", got, want)
+	}
+
+	// Un symlink que apunta al otro sistema de archivos tampoco cruza el límite
+	if err := os.Symlink("montaje", filepath.Join(root, "atajo")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := scanned(t, Config{OneFileSystem: true, FollowSymlinks: true}, root), []string{"a.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("con -one-file-system y -follow-symlinks: %v, quería %v", got, want)
+	}
+}
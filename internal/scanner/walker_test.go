@@ -0,0 +1,56 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tree crea los archivos indicados (ruta relativa -> contenido) bajo un directorio temporal.
+func tree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, data := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestScanReferences(t *testing.T) {
+	root := tree(t, map[string]string{
+		"fotos/a.jpg":         "a",
+		"fotos/archivo/b.jpg": "b",
+	})
+	fotos, archivo := filepath.Join(root, "fotos"), filepath.Join(root, "fotos", "archivo")
+
+	// Referencia dentro de la raíz: solo esa parte es de solo lectura
+	bySize, err := New(Config{References: []string{archivo}}).Scan(context.Background(), fotos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := map[string]bool{}
+	for _, g := range bySize {
+		for _, f := range g.Files {
+			ref[filepath.Base(f.Path)] = f.Reference
+		}
+	}
+	if len(ref) != 2 || ref["a.jpg"] || !ref["b.jpg"] {
+		t.Errorf("Reference por archivo = %v, quería a.jpg=false b.jpg=true", ref)
+	}
+
+	// Raíz dentro de (o igual a) una referencia: error, no una raíz que desaparece
+	for _, r := range []string{archivo, fotos} {
+		_, err := New(Config{References: []string{fotos}}).Scan(context.Background(), r)
+		if err == nil || !strings.Contains(err.Error(), "dentro de la referencia") {
+			t.Errorf("Scan(%s) con -ref %s: err = %v", r, fotos, err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sizeUnits: sufijos decimales (como ByteCountDecimal) y binarios.
+var sizeUnits = []struct {
+	suffix string
+	mult   int64
+}{
+	{"kib", 1 << 10}, {"mib", 1 << 20}, {"gib", 1 << 30}, {"tib", 1 << 40},
+	{"kb", 1e3}, {"mb", 1e6}, {"gb", 1e9}, {"tb", 1e12},
+	{"k", 1e3}, {"m", 1e6}, {"g", 1e9}, {"t", 1e12},
+	{"b", 1},
+}
+
+// ParseSize convierte "1024", "100MB", "1.5G" o "4KiB" a bytes.
+func ParseSize(s string) (int64, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(v, u.suffix) {
+			v = strings.TrimSpace(strings.TrimSuffix(v, u.suffix))
+			mult = u.mult
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("tamaño inválido: %q", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// ParseAge convierte una antigüedad ("1y", "6mo", "90min"... ver abajo) o una
+// fecha ("2024-01-31") en un instante absoluto, relativo a now.
+// Unidades: s, min, h, d, w, mo (30 días), y (365 días); también time.ParseDuration.
+// Un "m" suelto se rechaza: sería ambiguo entre minutos y meses.
+func ParseAge(s string, now time.Time) (time.Time, error) {
+	v := strings.TrimSpace(s)
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		return t, nil
+	}
+
+	const day = 24 * time.Hour
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"min", time.Minute}, {"mo", 30 * day},
+		{"d", day}, {"w", 7 * day}, {"y", 365 * day},
+	}
+	for _, u := range units {
+		if n, ok := strings.CutSuffix(v, u.suffix); ok {
+			f, err := strconv.ParseFloat(n, 64)
+			if err != nil || f < 0 {
+				break
+			}
+			return now.Add(-time.Duration(f * float64(u.unit))), nil
+		}
+	}
+
+	// "6m" para time.ParseDuration son 6 minutos; "1h30m" no es ambiguo
+	if n, ok := strings.CutSuffix(v, "m"); ok {
+		if _, err := strconv.ParseFloat(n, 64); err == nil {
+			return time.Time{}, fmt.Errorf("antigüedad ambigua: %q (usa %smin para minutos o %smo para meses)", s, n, n)
+		}
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("antigüedad inválida: %q (ej: 30d, 1y, 2024-01-31)", s)
+	}
+	return now.Add(-d), nil
+}
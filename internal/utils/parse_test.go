@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"90min", 90 * time.Minute},
+		{"12h", 12 * time.Hour},
+		{"1h30m", 90 * time.Minute},
+		{"30d", 30 * 24 * time.Hour},
+		{"6mo", 180 * 24 * time.Hour},
+		{"1y", 365 * 24 * time.Hour},
+	}
+	for _, c := range cases {
+		got, err := ParseAge(c.in, now)
+		if err != nil {
+			t.Errorf("ParseAge(%q): %v", c.in, err)
+			continue
+		}
+		if d := now.Sub(got); d != c.want {
+			t.Errorf("ParseAge(%q) = now-%v, quiero now-%v", c.in, d, c.want)
+		}
+	}
+
+	for _, in := range []string{"6m", "1.5m", "abc", "-1d"} {
+		if _, err := ParseAge(in, now); err == nil {
+			t.Errorf("ParseAge(%q): se esperaba error", in)
+		}
+	}
+}
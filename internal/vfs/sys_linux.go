@@ -0,0 +1,22 @@
+//go:build linux
+
+package vfs
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// osSys extrae dispositivo, inodo y ctime (cambio de metadatos) del Stat_t de Linux.
+func osSys(info fs.FileInfo) SysInfo {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return SysInfo{}
+	}
+	return SysInfo{
+		DeviceID:   uint64(stat.Dev),
+		Inode:      uint64(stat.Ino),
+		ChangeTime: time.Unix(int64(stat.Ctim.Sec), int64(stat.Ctim.Nsec)),
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package vfs
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// osSys: sin ctime portable, la caché se valida solo con tamaño y mtime.
+func osSys(info fs.FileInfo) SysInfo {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return SysInfo{}
+	}
+	return SysInfo{DeviceID: uint64(stat.Dev), Inode: uint64(stat.Ino)}
+}
@@ -0,0 +1,55 @@
+// Package vfs abstrae el sistema de archivos que recorren el scanner y el hasher.
+//
+// Cualquier fs.FS sirve (testing/fstest.MapFS, un zip, un overlay...); OS es el
+// disco local. Las extensiones opcionales se detectan con type assertions, como
+// en io/fs:
+//   - fs.ReadLinkFS (Lstat): sin ella no hay symlinks, todo se ve ya resuelto
+//   - SysFS: identidad física (dispositivo, inodo, ctime) de cada archivo
+package vfs
+
+import (
+	"io/fs"
+	"os"
+	"time"
+)
+
+// OS es el disco local. A diferencia de os.DirFS, acepta rutas nativas
+// (absolutas o relativas al directorio actual), que son las que se reportan.
+var OS fs.FS = osFS{}
+
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error)          { return os.Open(name) }
+func (osFS) Stat(name string) (fs.FileInfo, error)      { return os.Stat(name) }
+func (osFS) Lstat(name string) (fs.FileInfo, error)     { return os.Lstat(name) }
+func (osFS) ReadLink(name string) (string, error)       { return os.Readlink(name) }
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+
+// SysInfo es la identidad física de un archivo. Con Inode == 0 no se detectan
+// hard links ni bucles de symlinks, y -one-file-system no tiene efecto.
+type SysInfo struct {
+	DeviceID   uint64
+	Inode      uint64
+	ChangeTime time.Time // ctime: solo valida la caché de hashes
+}
+
+// SysFS es la extensión opcional para un FS que conoce la identidad física de sus archivos.
+type SysFS interface {
+	fs.FS
+	Sys(info fs.FileInfo) SysInfo
+}
+
+// Sys devuelve la identidad física de info. Por orden: SysFS, un SysInfo
+// en info.Sys() (útil en fstest.MapFile.Sys) o el Stat_t del sistema operativo.
+func Sys(fsys fs.FS, info fs.FileInfo) SysInfo {
+	if s, ok := fsys.(SysFS); ok {
+		return s.Sys(info)
+	}
+	switch sys := info.Sys().(type) {
+	case SysInfo:
+		return sys
+	case *SysInfo:
+		return *sys
+	}
+	return osSys(info)
+}
@@ -0,0 +1,105 @@
+package dupes
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/soyunomas/dupedetector/internal/engine"
+)
+
+// Match es un archivo de B que ya tiene copia en A.
+type Match struct {
+	File     *File // El archivo en B
+	Copy     *File // Una copia en A (la que elige Options.Keep; un miembro de comprimido solo si no hay otra)
+	Verified bool  // Comparado byte a byte (Options.Verify)
+}
+
+// Comparison es el resultado de Compare.
+type Comparison struct {
+	Matches []Match  // Ordenados por la ruta en B
+	Unique  []*File  // Archivos de B sin copia en A, ordenados por ruta
+	Skipped []string // Entradas de B que no se compararon: filtradas, excluidas, ignoradas, symlinks...
+
+	FilesScanned  int64 // Archivos de B que pasaron los filtros
+	SourceFiles   int64 // Archivos de A (fuera de B) que pasaron los filtros
+	MatchedBytes  int64
+	UniqueBytes   int64
+	HashAlgorithm string
+	Duration      time.Duration
+	Errors        []PathError // Un archivo de B ilegible no está ni en Matches ni en Unique
+	Interrupted   bool
+	CacheErr      error
+}
+
+// Complete indica si se puede afirmar que todo B está en A: ningún archivo
+// único, ninguna entrada saltada, ningún error de lectura y sin interrupción.
+func (c *Comparison) Complete() bool {
+	return len(c.Unique) == 0 && len(c.Skipped) == 0 && len(c.Errors) == 0 && !c.Interrupted
+}
+
+// Compare responde "¿puedo vaciar B porque todo está ya en A?": clasifica cada
+// archivo de b según tenga o no una copia (mismo contenido) en a. No agrupa todo
+// contra todo: de A solo se leen los archivos cuyo tamaño coincide con alguno de B.
+//
+// Lo que cuelgue de B no cuenta como copia aunque también esté bajo A. Los
+// filtros de Options se aplican a ambos lados (un archivo de B filtrado no se
+// compara: figura en Skipped e impide Complete); Archives solo busca copias dentro de comprimidos de A;
+// References y Dirs no se usan. Si ctx se cancela, devuelve un resultado parcial
+// (Interrupted = true) junto con ctx.Err().
+func Compare(ctx context.Context, a, b []string, opts Options) (*Comparison, error) {
+	opts.References, opts.Dirs = nil, false
+	s, err := newSession(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := s.runner.Compare(ctx, a, b)
+	if err != nil && (stats == nil || !stats.Interrupted) {
+		return nil, err
+	}
+	s.saveCache()
+
+	c := newComparison(stats)
+	c.HashAlgorithm = s.hasher.Name()
+	c.CacheErr = s.cacheErr
+	return c, err
+}
+
+// newComparison convierte los grupos del engine en un Match por archivo de B.
+func newComparison(stats *engine.CompareStats) *Comparison {
+	c := &Comparison{
+		Unique:       stats.Unique,
+		Skipped:      stats.Skipped,
+		FilesScanned: stats.TargetFiles,
+		SourceFiles:  stats.SourceFiles,
+		Duration:     stats.Duration,
+		Errors:       stats.Errors,
+		Interrupted:  stats.Interrupted,
+	}
+	for _, g := range stats.Found {
+		src := firstReference(g.Files)
+		for _, f := range g.Files {
+			if !f.Reference {
+				c.Matches = append(c.Matches, Match{File: f, Copy: src, Verified: g.Verified})
+				c.MatchedBytes += f.Size
+			}
+		}
+	}
+	sort.Slice(c.Matches, func(i, j int) bool { return c.Matches[i].File.Path < c.Matches[j].File.Path })
+	for _, f := range c.Unique {
+		c.UniqueBytes += f.Size
+	}
+	return c
+}
+
+// firstReference devuelve la primera copia de A del grupo. No tiene por qué ser
+// Files[0]: los miembros de comprimidos van al final, detrás de los archivos de B.
+func firstReference(files []*File) *File {
+	for _, f := range files {
+		if f.Reference {
+			return f
+		}
+	}
+	return nil
+}
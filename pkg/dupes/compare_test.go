@@ -0,0 +1,97 @@
+package dupes
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCompare(t *testing.T) {
+	fsys := fstest.MapFS{
+		"archivo/2024/playa.jpg": {Data: []byte("arena y mar")},
+		"archivo/otra/monte.jpg": {Data: []byte("pinos y roca")},
+		"usb/DCIM/IMG_0001.jpg":  {Data: []byte("arena y mar")},  // Otro nombre, mismo contenido
+		"usb/DCIM/IMG_0002.jpg":  {Data: []byte("pinos y rocA")}, // Mismo tamaño, otro contenido
+		"usb/notas.txt":          {Data: []byte("solo aquí")},
+	}
+	c, err := Compare(context.Background(), []string{"archivo"}, []string{"usb"}, Options{FS: fsys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.FilesScanned != 3 {
+		t.Errorf("FilesScanned = %d, quería 3", c.FilesScanned)
+	}
+	if len(c.Matches) != 1 || c.Matches[0].File.Path != "usb/DCIM/IMG_0001.jpg" || c.Matches[0].Copy.Path != "archivo/2024/playa.jpg" {
+		t.Fatalf("Matches = %v", c.Matches)
+	}
+	if got, want := paths(c.Unique), []string{"usb/DCIM/IMG_0002.jpg", "usb/notas.txt"}; !slices.Equal(got, want) {
+		t.Errorf("Unique = %v, quería %v", got, want)
+	}
+	if c.Complete() {
+		t.Error("Complete() = true con archivos únicos")
+	}
+
+	// B dentro de A: lo que cuelga de B no es copia de sí mismo
+	c, err = Compare(context.Background(), []string{"."}, []string{"usb"}, Options{FS: fsys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Matches) != 1 || len(c.Unique) != 2 {
+		t.Errorf("B dentro de A: matches=%d unique=%d, quería 1 y 2", len(c.Matches), len(c.Unique))
+	}
+}
+
+// La única copia en A está dentro de un zip: sortGroups deja el miembro al final,
+// detrás del archivo de B, y aun así tiene que ser la copia del Match.
+func TestCompareArchiveOnlyCopy(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/backup.zip": {Data: zipOf(t, map[string]string{"notas/lista.txt": "leche, pan, huevos\n"})},
+		"b/lista.txt":  {Data: []byte("leche, pan, huevos\n")},
+	}
+	for _, verify := range []bool{false, true} {
+		c, err := Compare(context.Background(), []string{"a"}, []string{"b"}, Options{FS: fsys, Archives: true, Verify: verify})
+		if err != nil {
+			t.Fatalf("verify=%v: %v", verify, err)
+		}
+		if len(c.Matches) != 1 || len(c.Unique) != 0 {
+			t.Fatalf("verify=%v: matches=%d unique=%d, quería 1 y 0", verify, len(c.Matches), len(c.Unique))
+		}
+		m := c.Matches[0]
+		if m.File.Path != "b/lista.txt" || m.Copy.Path != "a/backup.zip"+ArchiveSep+"notas/lista.txt" {
+			t.Errorf("verify=%v: match %s -> %s", verify, m.File.Path, m.Copy.Path)
+		}
+		if m.Verified != verify {
+			t.Errorf("verify=%v: Verified = %v", verify, m.Verified)
+		}
+		if !c.Complete() {
+			t.Errorf("verify=%v: Complete() = false", verify)
+		}
+	}
+}
+
+// Lo que se salta en B (ignorado, excluido o filtrado) no se ha comparado.
+func TestCompareSkippedBlocksComplete(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/foto.jpg":        {Data: []byte("jpg")},
+		"b/foto.jpg":        {Data: []byte("jpg")},
+		"b/.dupeignore":     {Data: []byte("borrador.txt\n")},
+		"b/borrador.txt":    {Data: []byte("sin copia")},
+		"b/TRASH_BIN/x.jpg": {Data: []byte("jpg")},
+	}
+	opts := Options{FS: fsys, Excludes: []string{"TRASH_BIN"}, IgnoreFiles: []string{DefaultIgnoreFile}, Extensions: []string{"jpg"}}
+	c, err := Compare(context.Background(), []string{"a"}, []string{"b"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Matches) != 1 || len(c.Unique) != 0 {
+		t.Fatalf("matches=%d unique=%d, quería 1 y 0", len(c.Matches), len(c.Unique))
+	}
+	want := []string{"b/.dupeignore", "b/TRASH_BIN", "b/borrador.txt"}
+	if !slices.Equal(c.Skipped, want) {
+		t.Fatalf("Skipped = %v, quería %v", c.Skipped, want)
+	}
+	if c.Complete() {
+		t.Error("Complete() = true con entradas de B sin comparar")
+	}
+}
@@ -0,0 +1,392 @@
+// Package dupes es la API pública del detector de duplicados de dupedetector.
+//
+// Scan recorre una o varias raíces y devuelve los grupos de archivos con
+// contenido idéntico, ya clasificados (Keeper, duplicados, hard links y copias
+// en carpetas de referencia). No escribe en consola ni modifica ningún archivo:
+// el avance se recibe, si se quiere, con Options.Progress.
+//
+//	res, err := dupes.Scan(ctx, []string{"/srv/fotos"}, dupes.Options{
+//		MinSize:       1 << 20,
+//		HashAlgorithm: dupes.AlgoSHA256,
+//	})
+//	if err != nil && !res.Interrupted {
+//		return err
+//	}
+//	for _, g := range res.Groups {
+//		fmt.Println(g.Keeper.Path, len(g.Duplicates))
+//	}
+//
+// Compare, en cambio, solo responde qué archivos de una carpeta ya tienen copia
+// en otra (y cuáles no).
+package dupes
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/soyunomas/dupedetector/internal/cache"
+	"github.com/soyunomas/dupedetector/internal/engine"
+	"github.com/soyunomas/dupedetector/internal/entities"
+	"github.com/soyunomas/dupedetector/internal/hasher"
+	"github.com/soyunomas/dupedetector/internal/scanner"
+	"github.com/soyunomas/dupedetector/internal/vfs"
+)
+
+// Tipos compartidos con el engine. Son alias: no hace falta convertir nada.
+type (
+	File      = entities.FileInfo  // Un archivo en disco (ruta, tamaño, mtime, dispositivo, inodo, hash)
+	Digest    = entities.Digest    // Hash de contenido en hexadecimal
+	PathError = entities.PathError // Una ruta que no se pudo leer
+	Phase     = entities.Phase     // Fase del pipeline (scan, prehash, hash, verify)
+	ErrorKind = entities.ErrorKind // Clasificación de un PathError
+
+	Dir          = entities.DirInfo      // Una carpeta (Options.Dirs)
+	DirGroup     = entities.DirGroup     // Una carpeta que se conserva y las que sobran
+	DirGroupKind = entities.DirGroupKind // Idénticas o contenidas
+
+	Event     = engine.Event     // Aviso de progreso
+	EventKind = engine.EventKind // Tipo de aviso de progreso
+
+	SysInfo = vfs.SysInfo // Identidad física de un archivo (ver SysFS)
+	SysFS   = vfs.SysFS   // Extensión opcional de Options.FS: dispositivo, inodo y ctime
+
+	Hasher    = hasher.Hasher    // Calcula digests con uno de Algorithms() (ver NewHasher)
+	FileStats = hasher.FileStats // Tamaño, dispositivo e inodo del descriptor hasheado
+)
+
+// OS es el disco local: el FS que usa Scan cuando Options.FS es nil. A diferencia
+// de os.DirFS, acepta rutas nativas (absolutas o relativas).
+var OS fs.FS = vfs.OS
+
+// Fases del pipeline.
+const (
+	PhaseScan    = entities.PhaseScan
+	PhasePreHash = entities.PhasePreHash
+	PhaseHash    = entities.PhaseHash
+	PhaseVerify  = entities.PhaseVerify
+)
+
+// Tipos de DirGroup.
+const (
+	DirIdentical = entities.DirIdentical // Árboles idénticos
+	DirSubset    = entities.DirSubset    // Todo el contenido de la redundante está dentro del Keeper
+)
+
+// Tipos de error de PathError.
+const (
+	ErrPermission = entities.ErrPermission
+	ErrNotFound   = entities.ErrNotFound
+	ErrIO         = entities.ErrIO
+	ErrOther      = entities.ErrOther
+)
+
+// Tipos de evento de progreso (ver Event).
+const (
+	EventPhaseStarted    = engine.EventPhaseStarted
+	EventRootStarted     = engine.EventRootStarted
+	EventFilesDiscovered = engine.EventFilesDiscovered
+	EventProgress        = engine.EventProgress
+	EventPhaseFinished   = engine.EventPhaseFinished
+	EventError           = engine.EventError
+)
+
+// Algoritmos de hash disponibles.
+const (
+	AlgoXXHash  = hasher.AlgoXXHash  // xxHash64 (por defecto): el más rápido
+	AlgoXXH3    = hasher.AlgoXXH3    // XXH3 de 128 bits
+	AlgoSHA256  = hasher.AlgoSHA256  // Criptográfico, compatible con sha256sum
+	AlgoBLAKE2b = hasher.AlgoBLAKE2b // BLAKE2b-512, compatible con b2sum
+)
+
+// KeepStrategy decide qué archivo de cada grupo es el Keeper (el que se conserva).
+type KeepStrategy = engine.KeepStrategy
+
+const (
+	KeepShortestPath = engine.KeepShortestPath // Ruta más corta (por defecto)
+	KeepLongestPath  = engine.KeepLongestPath  // Ruta más larga
+	KeepOldest       = engine.KeepOldest       // mtime más antiguo
+	KeepNewest       = engine.KeepNewest       // mtime más reciente
+)
+
+// keepNames son los nombres que acepta ParseKeepStrategy.
+var keepNames = map[string]KeepStrategy{
+	"shortest": KeepShortestPath,
+	"longest":  KeepLongestPath,
+	"oldest":   KeepOldest,
+	"newest":   KeepNewest,
+}
+
+// ParseKeepStrategy convierte "shortest", "longest", "oldest" o "newest" en una KeepStrategy.
+func ParseKeepStrategy(name string) (KeepStrategy, error) {
+	s, ok := keepNames[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("estrategia desconocida: %s", name)
+	}
+	return s, nil
+}
+
+// DefaultExcludes son los nombres que la CLI ignora por defecto.
+// Scan no los aplica por su cuenta: hay que incluirlos en Options.Excludes.
+var DefaultExcludes = scanner.DefaultExcludes
+
+// DefaultIgnoreFile es el archivo de exclusiones por directorio (semántica .gitignore).
+const DefaultIgnoreFile = scanner.DefaultIgnoreFile
+
+// ValidatePattern comprueba la sintaxis de un glob de Options.Excludes o Options.Includes.
+// Scan ya valida todos los patrones; esto sirve para avisar antes de empezar.
+func ValidatePattern(p string) error {
+	return scanner.ValidatePattern(p)
+}
+
+// Algorithms devuelve los nombres de los algoritmos de hash disponibles.
+func Algorithms() []string {
+	return hasher.Algorithms()
+}
+
+// NewHasher devuelve el Hasher de un algoritmo (vacío = AlgoXXHash), el mismo
+// que usa Scan: sirve para re-verificar un Digest antes de actuar.
+func NewHasher(algo string) (Hasher, error) {
+	return hasher.New(algo)
+}
+
+// Options configura Scan. El valor cero es válido: todo archivo de 0 bytes o
+// más, xxhash, Keeper por ruta más corta, sin caché y sin filtros.
+type Options struct {
+	// Filtros de tamaño y fecha
+	MinSize   int64     // Tamaño mínimo en bytes
+	MaxSize   int64     // Tamaño máximo en bytes (0 = sin límite)
+	NewerThan time.Time // Solo archivos modificados después (cero = sin límite)
+	OlderThan time.Time // Solo archivos modificados antes (cero = sin límite)
+
+	// Filtros de ruta
+	Extensions        []string         // Extensiones permitidas ("mp4" o ".mp4"; vacío = todas)
+	ExcludeExtensions []string         // Extensiones a ignorar
+	Excludes          []string         // Nombres o globs a ignorar ("node_modules", "**/*.tmp", "build/")
+	Includes          []string         // Globs de archivos a considerar (vacío = todos)
+	ExcludeRegex      []*regexp.Regexp // Regex sobre la ruta relativa a cada raíz
+	IncludeRegex      []*regexp.Regexp
+	IgnoreFiles       []string // Archivos estilo .gitignore a respetar (ej: DefaultIgnoreFile, ".gitignore")
+
+	// Recorrido
+	FollowSymlinks bool     // Seguir symlinks (con detección de bucles); por defecto se ignoran
+	OneFileSystem  bool     // No cruzar puntos de montaje
+	References     []string // Carpetas de solo lectura: se escanean, sus archivos nunca son duplicados a eliminar
+
+	// Archives busca también dentro de .zip, .tar, .tar.gz y .tar.bz2: cada miembro
+	// es un File virtual (Path "copia.zip!/src/main.go", File.Archive = "copia.zip").
+	// Los miembros nunca son Keeper si hay un archivo suelto, y nunca son Duplicates.
+	Archives bool
+
+	// Detección
+	Keep          KeepStrategy
+	HashAlgorithm string // Uno de Algorithms() (vacío = AlgoXXHash)
+	Verify        bool   // Comparar byte a byte contra el Keeper antes de declarar duplicados
+	UseCache      bool   // Caché persistente de hashes en el directorio de caché del usuario
+
+	// Dirs reporta además carpetas enteras redundantes: árboles idénticos (por un
+	// digest tipo Merkle de sus contenidos y su estructura, subcarpetas vacías
+	// incluidas, sin contar nombres salvo en archivos vacíos) y carpetas cuyo
+	// contenido completo está dentro de otra. Una carpeta con algo filtrado,
+	// excluido o ilegible, o sin ningún byte de datos, nunca es redundante:
+	// conviene MinSize = 0.
+	Dirs bool
+
+	// FS es el sistema de archivos a escanear (nil = disco local). Sirve cualquier
+	// fs.FS: testing/fstest.MapFS, un zip, un overlay... Con un FS propio, las
+	// raíces y las rutas del resultado son rutas de ese FS ("." o "fotos/2024"),
+	// y no se usa la caché. Los symlinks requieren fs.ReadLinkFS; los hard links,
+	// SysFS o un SysInfo en FileInfo.Sys().
+	FS fs.FS
+
+	// Progress recibe eventos de avance. Se invoca siempre desde la goroutine de
+	// Scan, nunca en paralelo, y debe ser rápida (puede haber un evento por archivo).
+	Progress func(Event)
+}
+
+// Group es un conjunto de archivos con el mismo contenido.
+type Group struct {
+	Hash     Digest
+	Size     int64 // Tamaño de cada archivo del grupo
+	Verified bool  // Comparado byte a byte (Options.Verify)
+
+	Keeper     *File   // El archivo que se conserva
+	Duplicates []*File // Copias físicas: liberan espacio si se eliminan
+	HardLinks  []*File // Mismo inodo que otro miembro: no ocupan espacio extra
+	References []*File // Copias en carpetas de referencia: nunca se tocan
+	Archived   []*File // Copias dentro de archivos comprimidos (Options.Archives): nunca se tocan
+}
+
+// Result es el resultado de Scan.
+type Result struct {
+	Groups []Group
+	Dirs   []*DirGroup // Solo con Options.Dirs
+
+	FilesScanned    int64 // Archivos que pasaron los filtros
+	Duplicates      int64 // Total de Group.Duplicates
+	HardLinks       int64 // Total de Group.HardLinks
+	ReferenceCopies int64 // Total de Group.References
+	ArchivedCopies  int64 // Total de Group.Archived
+	VerifiedGroups  int64
+	BytesSaved      int64 // Espacio que se liberaría eliminando todos los duplicados
+	RedundantDirs   int64 // Total de DirGroup.Redundant
+	DirBytesSaved   int64 // Tamaño total de las carpetas redundantes
+
+	HashAlgorithm string        // Algoritmo usado
+	Duration      time.Duration // Duración del escaneo
+	Errors        []PathError   // Rutas que no se pudieron leer: si hay alguna, el resultado está incompleto
+	Interrupted   bool          // ctx se canceló: resultado parcial
+	CacheErr      error         // Fallo al abrir o guardar la caché (el escaneo no se ve afectado)
+}
+
+// Scan busca archivos duplicados en roots (y en Options.References).
+// Las raíces anidadas dentro de otra se recorren una sola vez.
+//
+// Si ctx se cancela, devuelve un Result parcial (Interrupted = true) junto con
+// ctx.Err(): los grupos devueltos son duplicados reales, pero puede faltar alguno.
+// Cualquier otro error es de configuración o fatal, y el Result es nil.
+func Scan(ctx context.Context, roots []string, opts Options) (*Result, error) {
+	s, err := newSession(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := s.runner.Run(ctx, roots...)
+	if err != nil && (stats == nil || !stats.Interrupted) {
+		return nil, err
+	}
+	s.saveCache()
+
+	res := newResult(stats)
+	res.HashAlgorithm = s.hasher.Name()
+	res.CacheErr = s.cacheErr
+	return res, err
+}
+
+// session es lo común a Scan y Compare: opciones validadas, engine y caché.
+type session struct {
+	runner   *engine.Runner
+	hasher   hasher.Hasher
+	cache    *cache.Cache
+	cacheErr error
+}
+
+func newSession(opts Options) (*session, error) {
+	h, err := hasher.New(opts.HashAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range append(append([]string{}, opts.Excludes...), opts.Includes...) {
+		if err := ValidatePattern(p); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &session{hasher: h}
+	if opts.UseCache {
+		// Un fallo de caché nunca impide el escaneo: se sigue sin ella
+		var err error
+		if s.cache, err = cache.Open(h.Name()); err != nil {
+			s.cache, s.cacheErr = nil, fmt.Errorf("caché desactivada: %w", err)
+		}
+	}
+
+	s.runner = engine.New(engine.Options{
+		MinSize:        opts.MinSize,
+		MaxSize:        opts.MaxSize,
+		NewerThan:      opts.NewerThan,
+		OlderThan:      opts.OlderThan,
+		Extensions:     opts.Extensions,
+		ExcludeExts:    opts.ExcludeExtensions,
+		Excludes:       opts.Excludes,
+		Includes:       opts.Includes,
+		ExcludeRegex:   opts.ExcludeRegex,
+		IncludeRegex:   opts.IncludeRegex,
+		IgnoreFiles:    opts.IgnoreFiles,
+		FollowSymlinks: opts.FollowSymlinks,
+		OneFileSystem:  opts.OneFileSystem,
+		Archives:       opts.Archives,
+		References:     opts.References,
+		Strategy:       opts.Keep,
+		Verify:         opts.Verify,
+		Dirs:           opts.Dirs,
+		Hasher:         h,
+		Cache:          s.cache,
+		Progress:       opts.Progress,
+		FS:             opts.FS,
+	})
+	return s, nil
+}
+
+// saveCache guarda la caché (si la hay); un fallo queda en cacheErr.
+func (s *session) saveCache() {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.Save(); err != nil {
+		s.cacheErr = fmt.Errorf("no se pudo guardar la caché: %w", err)
+	}
+}
+
+// newResult clasifica los miembros de cada grupo a partir de las estadísticas del engine.
+func newResult(stats *engine.Stats) *Result {
+	res := &Result{
+		FilesScanned: stats.TotalFilesScanned,
+		Duration:     stats.Duration,
+		Errors:       stats.Errors,
+		Interrupted:  stats.Interrupted,
+		Dirs:         stats.Dirs,
+	}
+	for _, g := range stats.Dirs {
+		for _, d := range g.Redundant {
+			res.RedundantDirs++
+			res.DirBytesSaved += d.Size
+		}
+	}
+
+	type sysID struct{ dev, inode uint64 }
+
+	for _, fg := range stats.Groups {
+		if fg.Count < 2 {
+			continue
+		}
+
+		keeper := fg.Files[0]
+		g := Group{Hash: keeper.Hash, Size: keeper.Size, Verified: fg.Verified, Keeper: keeper}
+
+		// Cada inodo cuenta una sola vez: el resto de rutas al mismo inodo son hard links.
+		// Inode == 0: el FS no identifica archivos físicos, cada ruta es una copia.
+		seen := map[sysID]bool{{keeper.DeviceID, keeper.Inode}: keeper.Inode != 0}
+		for _, f := range fg.Files[1:] {
+			id := sysID{f.DeviceID, f.Inode}
+			switch {
+			case f.Archive != "":
+				// Virtual: se reporta, pero dentro de un comprimido no se puede actuar
+				g.Archived = append(g.Archived, f)
+				continue
+			case seen[id]:
+				g.HardLinks = append(g.HardLinks, f)
+			case f.Reference:
+				// Solo lectura: jamás se convierte en víctima
+				g.References = append(g.References, f)
+			default:
+				g.Duplicates = append(g.Duplicates, f)
+				res.BytesSaved += f.Size
+			}
+			seen[id] = f.Inode != 0
+		}
+
+		res.Groups = append(res.Groups, g)
+		res.Duplicates += int64(len(g.Duplicates))
+		res.HardLinks += int64(len(g.HardLinks))
+		res.ReferenceCopies += int64(len(g.References))
+		res.ArchivedCopies += int64(len(g.Archived))
+		if g.Verified {
+			res.VerifiedGroups++
+		}
+	}
+	return res
+}
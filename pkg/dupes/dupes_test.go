@@ -0,0 +1,345 @@
+package dupes
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/fs"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+// paths devuelve las rutas de files, ordenadas.
+func paths(files []*File) []string {
+	out := make([]string, 0, len(files))
+	for _, f := range files {
+		out = append(out, f.Path)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// scan ejecuta Scan sobre fsys desde "." y falla el test si hay errores.
+func scan(t *testing.T, fsys fs.FS, opts Options) *Result {
+	t.Helper()
+	opts.FS = fsys
+	res, err := Scan(context.Background(), []string{"."}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Errors) > 0 {
+		t.Fatalf("errores inesperados: %v", res.Errors)
+	}
+	return res
+}
+
+// groupOf devuelve el grupo cuyo Keeper es keeper, o falla el test.
+func groupOf(t *testing.T, res *Result, keeper string) Group {
+	t.Helper()
+	for _, g := range res.Groups {
+		if g.Keeper.Path == keeper {
+			return g
+		}
+	}
+	t.Fatalf("no hay grupo con Keeper %s", keeper)
+	return Group{}
+}
+
+func TestScanGroups(t *testing.T) {
+	fsys := fstest.MapFS{
+		"fotos/playa.jpg":         {Data: []byte("arena y mar")},
+		"backup/fotos/playa.jpg":  {Data: []byte("arena y mar")},
+		"descargas/playa (1).jpg": {Data: []byte("arena y mar")},
+		"fotos/monte.jpg":         {Data: []byte("pinos y roca")}, // Mismo tamaño, otro contenido
+		"notas.txt":               {Data: []byte("único")},
+		"docs/informe.pdf":        {Data: []byte("pdf pdf")},
+		"docs/informe-copia.pdf":  {Data: []byte("pdf pdf")},
+	}
+	res := scan(t, fsys, Options{})
+
+	if res.FilesScanned != int64(len(fsys)) {
+		t.Errorf("FilesScanned = %d, quería %d", res.FilesScanned, len(fsys))
+	}
+	if len(res.Groups) != 2 {
+		t.Fatalf("%d grupos, quería 2", len(res.Groups))
+	}
+
+	// Keep por defecto: la ruta más corta
+	g := groupOf(t, res, "fotos/playa.jpg")
+	if got, want := paths(g.Duplicates), []string{"backup/fotos/playa.jpg", "descargas/playa (1).jpg"}; !slices.Equal(got, want) {
+		t.Errorf("Duplicates = %v, quería %v", got, want)
+	}
+	if g.Size != int64(len("arena y mar")) || g.Verified {
+		t.Errorf("Size = %d, Verified = %v", g.Size, g.Verified)
+	}
+	groupOf(t, res, "docs/informe.pdf")
+
+	if res.Duplicates != 3 {
+		t.Errorf("Duplicates = %d, quería 3", res.Duplicates)
+	}
+	if want := int64(2*len("arena y mar") + len("pdf pdf")); res.BytesSaved != want {
+		t.Errorf("BytesSaved = %d, quería %d", res.BytesSaved, want)
+	}
+}
+
+func TestScanReferences(t *testing.T) {
+	fsys := fstest.MapFS{
+		"archivo/a.txt": {Data: []byte("contenido")},
+		"usb/a.txt":     {Data: []byte("contenido")},
+		"usb/b.txt":     {Data: []byte("contenido")},
+	}
+	res := scan(t, fsys, Options{References: []string{"archivo"}, Keep: mustKeep(t, "longest")})
+
+	// Una referencia es Keeper aunque la estrategia prefiera otra ruta
+	g := groupOf(t, res, "archivo/a.txt")
+	if got, want := paths(g.Duplicates), []string{"usb/a.txt", "usb/b.txt"}; !slices.Equal(got, want) {
+		t.Errorf("Duplicates = %v, quería %v", got, want)
+	}
+
+	// Una raíz dentro de una referencia es un error, no una raíz que desaparece
+	_, err := Scan(context.Background(), []string{"usb"}, Options{FS: fsys, References: []string{"."}})
+	if err == nil {
+		t.Error("Scan con la raíz dentro de la referencia no falló")
+	}
+}
+
+func mustKeep(t *testing.T, name string) KeepStrategy {
+	t.Helper()
+	k, err := ParseKeepStrategy(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// Un grupo con el mismo hash pero distinto contenido se divide al verificar.
+// La colisión se simula cambiando el contenido justo antes de la Fase 4.
+func TestScanVerifySplits(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.bin": {Data: []byte("xxxxxxxx")},
+		"b.bin": {Data: []byte("xxxxxxxx")},
+		"c.bin": {Data: []byte("xxxxxxxx")},
+		"d.bin": {Data: []byte("xxxxxxxx")},
+		"e.bin": {Data: []byte("xxxxxxxx")},
+	}
+	opts := Options{Verify: true}
+	opts.Progress = func(e Event) {
+		if e.Kind == EventPhaseStarted && e.Phase == PhaseVerify {
+			fsys["c.bin"].Data = []byte("yyyyyyyy")
+			fsys["d.bin"].Data = []byte("yyyyyyyy")
+			fsys["e.bin"].Data = []byte("zzzzzzzz")
+		}
+	}
+	res := scan(t, fsys, opts)
+
+	if len(res.Groups) != 2 || res.VerifiedGroups != 2 {
+		t.Fatalf("%d grupos (%d verificados), quería 2 y 2", len(res.Groups), res.VerifiedGroups)
+	}
+	for keeper, dups := range map[string][]string{"a.bin": {"b.bin"}, "c.bin": {"d.bin"}} {
+		g := groupOf(t, res, keeper)
+		if got := paths(g.Duplicates); !slices.Equal(got, dups) || !g.Verified {
+			t.Errorf("grupo %s: Duplicates = %v, Verified = %v; quería %v", keeper, got, g.Verified, dups)
+		}
+	}
+}
+
+func TestScanIgnoreFiles(t *testing.T) {
+	same := []byte("mismo contenido")
+	fsys := fstest.MapFS{
+		".dupeignore":     {Data: []byte("# temporales\n*.tmp\nbuild/\n/top.txt\n")},
+		"a.txt":           {Data: same},
+		"x.tmp":           {Data: same}, // *.tmp
+		"top.txt":         {Data: same}, // /top.txt: anclado a la raíz
+		"build/b.txt":     {Data: same}, // build/: la carpeta entera
+		"sub/.dupeignore": {Data: []byte("!keep.tmp\n")},
+		"sub/keep.tmp":    {Data: same}, // Un archivo más profundo manda
+		"sub/otro.tmp":    {Data: same},
+		"sub/top.txt":     {Data: same}, // El patrón anclado no baja
+	}
+	res := scan(t, fsys, Options{IgnoreFiles: []string{DefaultIgnoreFile}})
+
+	g := groupOf(t, res, "a.txt")
+	if got, want := paths(g.Duplicates), []string{"sub/keep.tmp", "sub/top.txt"}; !slices.Equal(got, want) {
+		t.Errorf("Duplicates = %v, quería %v", got, want)
+	}
+
+	// Sin IgnoreFiles, los .dupeignore son archivos normales
+	res = scan(t, fsys, Options{})
+	if g := groupOf(t, res, "a.txt"); len(g.Duplicates) != 6 {
+		t.Errorf("sin IgnoreFiles: %d duplicados, quería 6", len(g.Duplicates))
+	}
+}
+
+// zipOf construye un .zip en memoria con los archivos dados.
+func zipOf(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// tarGzOf construye un .tar.gz en memoria con los archivos dados.
+func tarGzOf(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestScanArchives(t *testing.T) {
+	fsys := fstest.MapFS{
+		"fotos/playa.jpg":       {Data: []byte("arena y mar")},
+		"copia/fotos/playa.jpg": {Data: []byte("arena y mar")},
+		"docs/informe.pdf":      {Data: []byte("pdf pdf")},
+		// Ruta más corta que cualquier archivo suelto: aun así nunca es el Keeper
+		"z.zip": {Data: zipOf(t, map[string]string{"p.jpg": "arena y mar"})},
+		"backup.tgz": {Data: tarGzOf(t, map[string]string{
+			"fotos/playa.jpg": "arena y mar",
+			"informe.pdf":     "pdf pdf",
+			"solo.txt":        "solo en el tar",
+		})},
+	}
+
+	for _, verify := range []bool{false, true} {
+		res := scan(t, fsys, Options{Archives: true, Verify: verify})
+
+		g := groupOf(t, res, "fotos/playa.jpg")
+		if got, want := paths(g.Duplicates), []string{"copia/fotos/playa.jpg"}; !slices.Equal(got, want) {
+			t.Errorf("verify=%v: Duplicates = %v, quería %v", verify, got, want)
+		}
+		if got, want := paths(g.Archived), []string{"backup.tgz!/fotos/playa.jpg", "z.zip!/p.jpg"}; !slices.Equal(got, want) {
+			t.Errorf("verify=%v: Archived = %v, quería %v", verify, got, want)
+		}
+		for _, f := range g.Archived {
+			if f.Archive == "" {
+				t.Errorf("verify=%v: %s sin File.Archive", verify, f.Path)
+			}
+		}
+
+		// Un grupo sin más copia suelta que el Keeper no libera nada
+		g = groupOf(t, res, "docs/informe.pdf")
+		if len(g.Duplicates) != 0 || len(g.Archived) != 1 || g.Archived[0].Path != "backup.tgz!/informe.pdf" {
+			t.Errorf("verify=%v: informe: Duplicates = %v, Archived = %v", verify, paths(g.Duplicates), paths(g.Archived))
+		}
+		if res.Duplicates != 1 || res.ArchivedCopies != 3 {
+			t.Errorf("verify=%v: Duplicates = %d, ArchivedCopies = %d; quería 1 y 3", verify, res.Duplicates, res.ArchivedCopies)
+		}
+	}
+
+	// Sin Archives, los comprimidos son archivos normales
+	res := scan(t, fsys, Options{})
+	if g := groupOf(t, res, "fotos/playa.jpg"); len(g.Archived) != 0 || len(res.Groups) != 1 {
+		t.Errorf("sin Archives: %d grupos, Archived = %v", len(res.Groups), paths(g.Archived))
+	}
+}
+
+// Una carpeta real cuyo nombre parece un comprimido ("x.zip!") se lee como
+// cualquier otra: solo son miembros las rutas que el escaneo sacó de un comprimido.
+func TestScanArchiveLikeDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt":        {Data: []byte("contenido")},
+		"x.zip!/a.txt": {Data: []byte("contenido")},
+		"b.zip":        {Data: zipOf(t, map[string]string{"a.txt": "contenido"})},
+	}
+	for _, verify := range []bool{false, true} {
+		res := scan(t, fsys, Options{Archives: true, Verify: verify})
+		g := groupOf(t, res, "a.txt")
+		if got, want := paths(g.Duplicates), []string{"x.zip!/a.txt"}; !slices.Equal(got, want) {
+			t.Errorf("verify=%v: Duplicates = %v, quería %v", verify, got, want)
+		}
+		if got, want := paths(g.Archived), []string{"b.zip!/a.txt"}; !slices.Equal(got, want) {
+			t.Errorf("verify=%v: Archived = %v, quería %v", verify, got, want)
+		}
+	}
+}
+
+func TestScanDirs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"orig/a.jpg":       {Data: []byte("AAAA")},
+		"orig/b.jpg":       {Data: []byte("BBBB")},
+		"copia/a.jpg":      {Data: []byte("AAAA")}, // Idéntica a orig
+		"copia/b.jpg":      {Data: []byte("BBBB")},
+		"parcial/b.jpg":    {Data: []byte("BBBB")}, // Contenida en otra
+		"hueco/a.jpg":      {Data: []byte("AAAA")}, // Igual que orig, más una subcarpeta vacía
+		"hueco/b.jpg":      {Data: []byte("BBBB")},
+		"hueco/vacia":      {Mode: fs.ModeDir},
+		"vacias1/.gitkeep": {}, // Sin datos: nunca redundantes
+		"vacias2/.gitkeep": {},
+	}
+	res := scan(t, fsys, Options{Dirs: true})
+
+	kinds := map[DirGroupKind][]*DirGroup{}
+	for _, g := range res.Dirs {
+		kinds[g.Kind] = append(kinds[g.Kind], g)
+		for _, d := range append([]*Dir{g.Keeper}, g.Redundant...) {
+			if d.Path == "vacias1" || d.Path == "vacias2" {
+				t.Errorf("carpeta sin datos reportada: %s", d.Path)
+			}
+		}
+	}
+
+	if len(kinds[DirIdentical]) != 1 {
+		t.Fatalf("%d grupos idénticos, quería 1", len(kinds[DirIdentical]))
+	}
+	g := kinds[DirIdentical][0]
+	if g.Keeper.Path != "orig" || len(g.Redundant) != 1 || g.Redundant[0].Path != "copia" {
+		t.Errorf("idénticas: %s -> %v", g.Keeper.Path, g.Redundant)
+	}
+
+	if len(kinds[DirSubset]) != 1 {
+		t.Fatalf("%d grupos de contenidas, quería 1", len(kinds[DirSubset]))
+	}
+	g = kinds[DirSubset][0]
+	if len(g.Redundant) != 1 || g.Redundant[0].Path != "parcial" {
+		t.Errorf("contenidas: %s -> %v", g.Keeper.Path, g.Redundant)
+	}
+
+	if res.RedundantDirs != 2 || res.DirBytesSaved != 12 {
+		t.Errorf("RedundantDirs = %d, DirBytesSaved = %d; quería 2 y 12", res.RedundantDirs, res.DirBytesSaved)
+	}
+}
+
+// Los filtros dejan la carpeta incompleta: ya no es una copia de nada.
+func TestScanDirsFiltered(t *testing.T) {
+	fsys := fstest.MapFS{
+		"orig/a.jpg":  {Data: []byte("AAAA")},
+		"copia/a.jpg": {Data: []byte("AAAA")},
+		"copia/b.raw": {Data: []byte("RAW!")},
+	}
+	if res := scan(t, fsys, Options{Dirs: true}); len(res.Dirs) != 1 || res.Dirs[0].Kind != DirSubset {
+		t.Fatalf("sin filtros: %v, quería orig contenida en copia", res.Dirs)
+	}
+	if res := scan(t, fsys, Options{Dirs: true, ExcludeExtensions: []string{"raw"}}); len(res.Dirs) != 0 {
+		t.Errorf("con -exclude-ext raw: %d grupos, quería 0", len(res.Dirs))
+	}
+}
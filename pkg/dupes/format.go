@@ -0,0 +1,36 @@
+package dupes
+
+import (
+	"time"
+
+	"github.com/soyunomas/dupedetector/internal/archive"
+	"github.com/soyunomas/dupedetector/internal/utils"
+)
+
+// ArchiveSep separa la ruta de un comprimido de la de su miembro ("copia.zip!/src/main.go").
+const ArchiveSep = archive.Sep
+
+// SplitArchivePath separa la ruta de un miembro de comprimido (Options.Archives)
+// en el comprimido y la ruta interna. ok es false si p no tiene esa forma. Solo
+// mira el texto ("copia.zip!/" también puede ser una carpeta real): en un Result,
+// lo que dice si un File es un miembro es File.Archive.
+func SplitArchivePath(p string) (archivePath, member string, ok bool) {
+	return archive.Split(p)
+}
+
+// ByteCountDecimal formatea un tamaño en unidades decimales ("1.5 GB").
+func ByteCountDecimal(b int64) string {
+	return utils.ByteCountDecimal(b)
+}
+
+// ParseSize convierte "1024", "100MB", "1.5G" o "4KiB" a bytes.
+func ParseSize(s string) (int64, error) {
+	return utils.ParseSize(s)
+}
+
+// ParseAge convierte una antigüedad ("30d", "6mo", "1y", "90min") o una fecha
+// ("2024-01-31") en un instante absoluto, relativo a now. Sirve para
+// Options.NewerThan y Options.OlderThan.
+func ParseAge(s string, now time.Time) (time.Time, error) {
+	return utils.ParseAge(s, now)
+}